@@ -31,7 +31,7 @@ export const interruptSchema = z.discriminatedUnion("type", [
 export const blobSchema = z.object({
   id: z.string(),
   name: z.string(),
-  type: z.enum(["application/json", "image/png", "image/jpeg"]),
+  type: z.enum(["application/json", "image/png", "image/jpeg", "application/pdf", "text/csv"]),
   encoding: z.enum(["base64"]),
   size: z.number(),
   createdAt: z.date(),
@@ -0,0 +1 @@
+ALTER TABLE "tools" ADD COLUMN "output_schema" text;
\ No newline at end of file
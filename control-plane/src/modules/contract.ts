@@ -42,7 +42,7 @@ export const interruptSchema = z.discriminatedUnion("type", [
 export const blobSchema = z.object({
   id: z.string(),
   name: z.string(),
-  type: z.enum(["application/json", "image/png", "image/jpeg"]),
+  type: z.enum(["application/json", "image/png", "image/jpeg", "application/pdf", "text/csv"]),
   encoding: z.enum(["base64"]),
   size: z.number(),
   createdAt: z.date(),
@@ -488,6 +488,12 @@ export const definition = {
         .boolean()
         .default(false)
         .describe("Should retrieved Jobs be marked as running"),
+      waitTime: z.coerce
+        .number()
+        .min(0)
+        .max(20)
+        .optional()
+        .describe("Time in seconds to keep the request open waiting for jobs"),
     }),
     pathParams: z.object({
       clusterId: z.string(),
@@ -563,6 +569,29 @@ export const definition = {
       })
     ),
   },
+  createJobHeartbeat: {
+    method: "POST",
+    path: "/clusters/:clusterId/jobs/:jobId/heartbeat",
+    headers: z.object({
+      authorization: z.string(),
+      ...machineHeaders,
+    }),
+    pathParams: z.object({
+      clusterId: z.string(),
+      jobId: z.string(),
+    }),
+    responses: {
+      204: z.undefined(),
+      401: z.undefined(),
+      409: z.object({
+        message: z.string(),
+      }),
+    },
+    body: z.object({
+      message: z.string().optional(),
+      progress: z.number().min(0).max(100).optional(),
+    }),
+  },
 
   createMachine: {
     method: "POST",
@@ -578,6 +607,7 @@ export const definition = {
             name: z.string(),
             description: z.string().optional(),
             schema: z.string().optional(),
+            outputSchema: z.string().optional(),
             config: ToolConfigSchema.optional(),
           })
         )
@@ -588,6 +618,7 @@ export const definition = {
             name: z.string(),
             description: z.string().optional(),
             schema: z.string().optional(),
+            outputSchema: z.string().optional(),
             config: ToolConfigSchema.optional(),
           })
         )
@@ -1400,6 +1431,7 @@ export const definition = {
           name: z.string(),
           description: z.string().nullable(),
           schema: z.string().nullable(),
+          outputSchema: z.string().nullable(),
           config: ToolConfigSchema.nullable(),
           shouldExpire: z.boolean(),
           lastPingAt: z.date().nullable(),
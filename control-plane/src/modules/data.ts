@@ -203,6 +203,7 @@ export const tools = pgTable(
     name: varchar("name", { length: 1024 }).notNull(),
     description: text("description"),
     schema: text("schema"),
+    output_schema: text("output_schema"),
     config: json("config").$type<ToolConfig>(),
     hash: text("hash").notNull(),
     should_expire: boolean("should_expire").notNull(),
@@ -513,7 +514,7 @@ export const blobs = pgTable(
     }).notNull(),
     type: varchar("type", {
       length: 1024,
-      enum: ["application/json", "image/png", "image/jpeg"],
+      enum: ["application/json", "image/png", "image/jpeg", "application/pdf", "text/csv"],
     }).notNull(),
     size: integer("size").notNull(),
   },
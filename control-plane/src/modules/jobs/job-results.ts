@@ -60,6 +60,36 @@ export async function acknowledgeJob({
   return job;
 }
 
+// Extends the timeout of a running job, for machines executing long running tools.
+export async function recordJobHeartbeat({
+  jobId,
+  clusterId,
+  machineId,
+}: {
+  jobId: string;
+  clusterId: string;
+  machineId: string;
+}) {
+  const [updated] = await data.db
+    .update(data.jobs)
+    .set({
+      last_retrieved_at: sql`now()`,
+    })
+    .where(
+      and(
+        eq(data.jobs.id, jobId),
+        eq(data.jobs.cluster_id, clusterId),
+        eq(data.jobs.executing_machine_id, machineId),
+        eq(data.jobs.status, "running")
+      )
+    )
+    .returning({
+      jobId: data.jobs.id,
+    });
+
+  return !!updated;
+}
+
 export async function persistJobInterrupt({
   jobId,
   clusterId,
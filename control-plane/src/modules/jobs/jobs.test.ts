@@ -461,6 +461,7 @@ describe("pollJobs", () => {
       limit: 10,
       machineId,
       tools: [mockTargetFn],
+      timeout: 0,
     });
 
     expect(result2.length).toBe(0);
@@ -488,6 +489,7 @@ describe("pollJobs", () => {
         limit: 10,
         machineId: `testMachineId-${Math.random()}`,
         tools: [mockTargetFn],
+        timeout: 0,
       });
 
     const results = await Promise.all([
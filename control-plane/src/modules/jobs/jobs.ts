@@ -182,7 +182,8 @@ const waitForPendingJobsByTools = async ({
     return;
   }
 
-  if (Date.now() - start > timeout) {
+  // timeout is in seconds
+  if (Date.now() - start > timeout * 1000) {
     return;
   }
 
@@ -42,7 +42,7 @@ import { getRunMessagesForDisplayWithPolling } from "./runs/messages";
 import { getRunsByTag } from "./runs/tags";
 import { timeline } from "./timeline";
 import { getWorkflowTools, listTools, recordPoll, upsertToolDefinition } from "./tools";
-import { persistJobInterrupt } from "./jobs/job-results";
+import { persistJobInterrupt, recordJobHeartbeat } from "./jobs/job-results";
 import { createWorkflowExecution, listWorkflowExecutions, getWorkflowExecutionTimeline } from "./workflows/executions";
 
 const readFile = util.promisify(fs.readFile);
@@ -73,11 +73,22 @@ export const router = initServer().router(contract, {
         throw new BadRequestError(`Function ${fn.name} has an invalid schema.`);
       }
 
+      const outputSchema = fn.outputSchema
+        ? safeParse(fn.outputSchema)
+        : { success: true, data: undefined };
+
+      if (!outputSchema.success) {
+        throw new BadRequestError(`Function ${fn.name} has an invalid output schema.`);
+      }
+
       return {
         clusterId: machine.clusterId,
         name: fn.name,
         description: fn.description,
         schema: schema.data ? JSON.stringify(dereferenceSync(schema.data)) : undefined,
+        outputSchema: outputSchema.data
+          ? JSON.stringify(dereferenceSync(outputSchema.data))
+          : undefined,
         config: fn.config,
       };
     });
@@ -99,6 +110,7 @@ export const router = initServer().router(contract, {
               clusterId: machine.clusterId,
               description: fn.description,
               schema: fn.schema,
+              outputSchema: fn.outputSchema,
               config: fn.config,
             })
           )
@@ -786,7 +798,7 @@ export const router = initServer().router(contract, {
   },
   listJobs: async request => {
     const { clusterId } = request.params;
-    const { limit, acknowledge, status } = request.query;
+    const { limit, acknowledge, status, waitTime } = request.query;
     const tools = request.query.tools?.split(",").map(t => t.trim());
 
     if (acknowledge && status !== "pending") {
@@ -826,6 +838,7 @@ export const router = initServer().router(contract, {
           machineId,
           tools,
           limit,
+          timeout: waitTime,
         }),
     ]);
 
@@ -887,6 +900,42 @@ export const router = initServer().router(contract, {
       body: blob,
     };
   },
+  createJobHeartbeat: async request => {
+    const { clusterId, jobId } = request.params;
+    const { message, progress } = request.body;
+
+    const machine = request.request.getAuth().isMachine();
+    machine.canManage({ job: { clusterId, jobId } });
+
+    const machineId = request.headers["x-machine-id"];
+
+    if (!machineId) {
+      throw new BadRequestError("Request does not contain machine ID header");
+    }
+
+    const recorded = await recordJobHeartbeat({ jobId, clusterId, machineId });
+
+    // Not a 404, which SDKs take to mean that heartbeats aren't supported
+    if (!recorded) {
+      return {
+        status: 409,
+        body: {
+          message: "Job is not running on this machine",
+        },
+      };
+    }
+
+    logger.debug("Job heartbeat received", {
+      jobId,
+      message,
+      progress,
+    });
+
+    return {
+      status: 204,
+      body: undefined,
+    };
+  },
   getJob: async request => {
     const { clusterId, jobId } = request.params;
 
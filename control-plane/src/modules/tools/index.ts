@@ -74,6 +74,7 @@ export const getWorkflowTools = async ({
       name: data.tools.name,
       description: data.tools.description,
       schema: data.tools.schema,
+      outputSchema: data.tools.output_schema,
     })
     .from(data.tools)
     .where(
@@ -126,6 +127,7 @@ export const getToolDefinitions = async ({ clusterId }: { clusterId: string }) =
       name: data.tools.name,
       description: data.tools.description,
       schema: data.tools.schema,
+      outputSchema: data.tools.output_schema,
       config: data.tools.config,
     })
     .from(data.tools)
@@ -140,6 +142,7 @@ export const listTools = async ({ clusterId }: { clusterId: string }) => {
       name: data.tools.name,
       description: data.tools.description,
       schema: data.tools.schema,
+      outputSchema: data.tools.output_schema,
       config: data.tools.config,
       shouldExpire: data.tools.should_expire,
       lastPingAt: data.tools.last_ping_at,
@@ -163,6 +166,7 @@ export const getToolDefinition = async ({
       name: data.tools.name,
       description: data.tools.description,
       schema: data.tools.schema,
+      outputSchema: data.tools.output_schema,
       config: data.tools.config,
     })
     .from(data.tools)
@@ -189,6 +193,7 @@ export const searchTools = async ({
       name: data.tools.name,
       description: data.tools.description,
       schema: data.tools.schema,
+      outputSchema: data.tools.output_schema,
       config: data.tools.config,
       similarity,
     })
@@ -243,6 +248,7 @@ export async function upsertToolDefinition({
   name,
   description,
   schema,
+  outputSchema,
   config,
   clusterId,
   shouldExpire = true,
@@ -250,6 +256,7 @@ export async function upsertToolDefinition({
   name: string;
   description?: string;
   schema?: string;
+  outputSchema?: string;
   config?: ToolConfig;
   clusterId: string;
   shouldExpire?: boolean;
@@ -284,6 +291,7 @@ export async function upsertToolDefinition({
         name,
         description,
         schema,
+        outputSchema,
         config,
       })
     )
@@ -319,6 +327,7 @@ export async function upsertToolDefinition({
       name,
       description,
       schema,
+      output_schema: outputSchema,
       config,
       cluster_id: clusterId,
       last_ping_at: new Date(),
@@ -332,6 +341,7 @@ export async function upsertToolDefinition({
       set: {
         config,
         schema,
+        output_schema: outputSchema,
         description,
         last_ping_at: new Date(),
       },
@@ -0,0 +1,306 @@
+package inferable
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/invopop/jsonschema"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/client"
+)
+
+// MaxBlobSize is the largest Blob, in bytes, that a tool may return. Larger
+// blobs reject the job without being uploaded. Blobs are uploaded base64
+// encoded, so this is about three quarters of the control plane's request
+// limit, less room for the name and type.
+const MaxBlobSize = (maxRequestBytes - blobEnvelopeBytes) / 4 * 3
+
+// Room left in upload requests for everything but the content. Names are
+// limited to maxBlobNameLength, which takes up to 6 bytes a character once
+// escaped.
+const blobEnvelopeBytes = 8 << 10
+
+// Longest blob name the control plane stores.
+const maxBlobNameLength = 1024
+
+// Key of the object a Blob is encoded as until it is uploaded, shared with
+// the Node SDK.
+const blobDataKey = "__inferable_blob_data"
+
+// Types of blob the control plane accepts.
+var blobTypes = []string{"application/json", "image/png", "image/jpeg", "application/pdf", "text/csv"}
+
+// Deepest a result is searched for blobs.
+const maxBlobDepth = 32
+
+var blobType = reflect.TypeOf(Blob{})
+
+// Blob is file content, such as a PDF, an image or a CSV export, returned
+// by a tool. Blobs may be returned as the result or anywhere within it. They
+// are uploaded to the control plane separately from the result, which
+// references them as {"blobId", "name", "type", "size"}, so that their
+// content doesn't fill the model's context.
+//
+// Example:
+//
+//	func renderChart(input ChartInput, ctx inferable.ContextInput) (inferable.Blob, error) {
+//		png, err := chart.Render(input)
+//		return inferable.Blob{Name: "chart.png", Type: "image/png", Data: png}, err
+//	}
+type Blob struct {
+	// The file name, such as "chart.png".
+	Name string
+	// The MIME type, one of "application/json", "image/png", "image/jpeg",
+	// "application/pdf" or "text/csv". Images and PDFs are detected from the
+	// content if empty.
+	Type string
+	// The content. Ignored if Reader is set.
+	Data []byte
+	// Read for the content instead of Data. A Reader can only be read once,
+	// so tools that cache their results should use Data. Checking the result
+	// with ValidateOutput reads it, even for calls made with Invoke.
+	Reader io.Reader
+}
+
+// blobData is the content of a Blob as uploaded to the control plane.
+type blobData struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Size     int    `json:"size"`
+	Data     string `json:"data"`
+}
+
+// blobReference replaces an uploaded Blob in the job result.
+type blobReference struct {
+	ID   string `json:"blobId"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+// MarshalJSON encodes the blob's content, to be uploaded before the result
+// is persisted. It returns an error if the content is over MaxBlobSize or of
+// a type the control plane doesn't accept.
+func (b Blob) MarshalJSON() ([]byte, error) {
+	data := b.Data
+	if b.Reader != nil {
+		var err error
+		data, err = io.ReadAll(io.LimitReader(b.Reader, MaxBlobSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob '%s': %v", b.Name, err)
+		}
+	}
+
+	if utf8.RuneCountInString(b.Name) > maxBlobNameLength {
+		return nil, fmt.Errorf("blob name is over the maximum length of %d characters", maxBlobNameLength)
+	}
+
+	if len(data) > MaxBlobSize {
+		return nil, fmt.Errorf("blob '%s' is over the maximum size of %d bytes", b.Name, MaxBlobSize)
+	}
+
+	mimeType := b.Type
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if !slices.Contains(blobTypes, mimeType) {
+		return nil, fmt.Errorf("blob '%s' has unsupported type '%s', must be one of %s", b.Name, mimeType, strings.Join(blobTypes, ", "))
+	}
+
+	return json.Marshal(map[string]blobData{
+		blobDataKey: {
+			Name:     b.Name,
+			Type:     mimeType,
+			Encoding: "base64",
+			Size:     len(data),
+			Data:     base64.StdEncoding.EncodeToString(data),
+		},
+	})
+}
+
+// JSONSchema describes the reference a Blob is replaced with in the result,
+// which is what output schemas see.
+func (Blob) JSONSchema() *jsonschema.Schema {
+	properties := jsonschema.NewProperties()
+	properties.Set("blobId", &jsonschema.Schema{Type: "string"})
+	properties.Set("name", &jsonschema.Schema{Type: "string"})
+	properties.Set("type", &jsonschema.Schema{Type: "string"})
+	properties.Set("size", &jsonschema.Schema{Type: "integer"})
+
+	return &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             []string{"blobId", "name", "type", "size"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+}
+
+// uploadBlobs uploads the blobs in a result value and returns the value
+// with references in their place. Values without blobs are returned as is.
+func (s *pollingAgent) uploadBlobs(jobID string, value interface{}) (interface{}, error) {
+	encoded, err := encodeBlobs(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceBlobs(encoded, func(blob blobData) (interface{}, error) {
+		return s.uploadBlob(jobID, blob)
+	})
+}
+
+// encodeBlobs returns a value holding blobs as decoded JSON, with the content
+// of the blobs read into it so that readers aren't read again. Values
+// without blobs are returned as is.
+func encodeBlobs(value interface{}) (interface{}, error) {
+	if !containsBlob(reflect.ValueOf(value), 0) {
+		return value, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		var marshalErr *json.MarshalerError
+		if errors.As(err, &marshalErr) {
+			err = marshalErr.Unwrap()
+		}
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// replaceBlobs returns a copy of a value from encodeBlobs with the encoded
+// blobs in it replaced, leaving the value itself unchanged.
+func replaceBlobs(value interface{}, replace func(blob blobData) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if raw, ok := v[blobDataKey]; ok && len(v) == 1 {
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return nil, err
+			}
+
+			var blob blobData
+			if err := json.Unmarshal(encoded, &blob); err != nil {
+				return nil, err
+			}
+
+			return replace(blob)
+		}
+
+		replaced := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, err := replaceBlobs(item, replace)
+			if err != nil {
+				return nil, err
+			}
+			replaced[key] = item
+		}
+		return replaced, nil
+	case []interface{}:
+		replaced := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := replaceBlobs(item, replace)
+			if err != nil {
+				return nil, err
+			}
+			replaced[i] = item
+		}
+		return replaced, nil
+	}
+
+	return value, nil
+}
+
+func (s *pollingAgent) uploadBlob(jobID string, blob blobData) (blobReference, error) {
+	clusterId, err := s.inferable.getClusterId()
+	if err != nil {
+		return blobReference{}, fmt.Errorf("failed to get cluster id: %v", err)
+	}
+
+	body, err := json.Marshal(blob)
+	if err != nil {
+		return blobReference{}, fmt.Errorf("failed to marshal blob '%s': %v", blob.Name, err)
+	}
+
+	data, _, err, _ := s.inferable.fetchData(client.FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/jobs/%s/blobs", clusterId, jobID),
+		Method:  "POST",
+		Headers: s.inferable.machineHeaders(),
+		Body:    string(body),
+	})
+	if err != nil {
+		return blobReference{}, fmt.Errorf("failed to upload blob '%s': %v", blob.Name, err)
+	}
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return blobReference{}, fmt.Errorf("failed to parse blob upload response: %v", err)
+	}
+
+	return blobReference{
+		ID:   response.ID,
+		Name: blob.Name,
+		Type: blob.Type,
+		Size: blob.Size,
+	}, nil
+}
+
+// containsBlob reports whether a value holds a Blob anywhere within it.
+func containsBlob(v reflect.Value, depth int) bool {
+	if !v.IsValid() || depth > maxBlobDepth {
+		return false
+	}
+
+	if v.Type() == blobType {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return !v.IsNil() && containsBlob(v.Elem(), depth+1)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && containsBlob(v.Field(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if containsBlob(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if containsBlob(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
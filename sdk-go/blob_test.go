@@ -0,0 +1,144 @@
+package inferable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+type ReportOutput struct {
+	Summary string  `json:"summary"`
+	Pages   int     `json:"pages"`
+	Report  Blob    `json:"report"`
+	Charts  []*Blob `json:"charts"`
+}
+
+func TestBlobResults(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "report",
+		Func: func(input EchoInput, ctx ContextInput) ReportOutput {
+			return ReportOutput{
+				Summary: "Orders for " + input.Input,
+				Pages:   2,
+				Report:  Blob{Name: "report.json", Type: "application/json", Data: []byte(`{"total":10}`)},
+				Charts:  []*Blob{{Name: "chart.png", Reader: bytes.NewReader([]byte("\x89PNG\r\n\x1a\nchart"))}},
+			}
+		},
+		ValidateOutput: true,
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "huge",
+		Func: func(input EchoInput, ctx ContextInput) Blob {
+			return Blob{Name: "huge.bin", Reader: strings.NewReader(strings.Repeat("x", MaxBlobSize+1))}
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "csv",
+		Func: func(input EchoInput, ctx ContextInput) Blob {
+			return Blob{Name: "orders.csv", Type: "text/csv", Data: []byte("id,total\n1,10\n")}
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "largest",
+		Func: func(input EchoInput, ctx ContextInput) Blob {
+			// Names are escaped, each "<" taking 6 bytes
+			return Blob{Name: strings.Repeat("<", maxBlobNameLength), Type: "application/pdf", Data: make([]byte, MaxBlobSize)}
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "notes",
+		Func: func(input EchoInput, ctx ContextInput) Blob {
+			return Blob{Name: "notes.txt", Data: []byte("call back on Monday")}
+		},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	jobID := server.Enqueue(t, "report", EchoInput{Input: "May"})
+	hugeID := server.Enqueue(t, "huge", EchoInput{})
+	csvID := server.Enqueue(t, "csv", EchoInput{})
+	notesID := server.Enqueue(t, "notes", EchoInput{})
+	largestID := server.Enqueue(t, "largest", EchoInput{})
+	require.NoError(t, i.Tools.poll())
+
+	blobs := server.Blobs(jobID)
+	require.Len(t, blobs, 2)
+	byName := map[string]inferabletest.Blob{}
+	for _, blob := range blobs {
+		byName[blob.Name] = blob
+	}
+	assert.Equal(t, "application/json", byName["report.json"].Type)
+	assert.Equal(t, `{"total":10}`, string(byName["report.json"].Data))
+	assert.Equal(t, "image/png", byName["chart.png"].Type, "detected from the content")
+
+	result := server.WaitForResult(t, jobID)
+	assert.Equal(t, "resolution", result.ResultType)
+	assert.JSONEq(t, `{
+		"summary": "Orders for May",
+		"pages": 2,
+		"report": {"blobId": "`+byName["report.json"].ID+`", "name": "report.json", "type": "application/json", "size": 12},
+		"charts": [{"blobId": "`+byName["chart.png"].ID+`", "name": "chart.png", "type": "image/png", "size": 13}]
+	}`, string(result.Result))
+
+	// Local calls return the blobs as the tool did, after checking them
+	invoked, err := i.Tools.Invoke(context.Background(), "report", json.RawMessage(`{"Input": "June"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeResolution, invoked.Type)
+	output, ok := invoked.Value.(ReportOutput)
+	require.True(t, ok, "got %T", invoked.Value)
+	assert.Equal(t, "report.json", output.Report.Name)
+	assert.Equal(t, `{"total":10}`, string(output.Report.Data))
+
+	// Blobs over the size limit reject the job without being uploaded
+	result = server.WaitForResult(t, hugeID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Contains(t, string(result.Result), "blob 'huge.bin' is over the maximum size")
+	assert.Empty(t, server.Blobs(hugeID))
+
+	result = server.WaitForResult(t, csvID)
+	assert.Equal(t, "resolution", result.ResultType)
+	require.Len(t, server.Blobs(csvID), 1)
+	assert.Equal(t, "text/csv", server.Blobs(csvID)[0].Type)
+
+	// The largest blobs fit in the control plane's request limit
+	result = server.WaitForResult(t, largestID)
+	assert.Equal(t, "resolution", result.ResultType, string(result.Result))
+	require.Len(t, server.Blobs(largestID), 1)
+	assert.Len(t, server.Blobs(largestID)[0].Data, MaxBlobSize)
+
+	// Blobs of types the control plane doesn't accept reject the job too
+	result = server.WaitForResult(t, notesID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Contains(t, string(result.Result), "blob 'notes.txt' has unsupported type 'text/plain; charset=utf-8', must be one of application/json, image/png, image/jpeg, application/pdf, text/csv")
+	assert.Empty(t, server.Blobs(notesID))
+}
+
+func TestContainsBlob(t *testing.T) {
+	assert.True(t, containsBlob(reflect.ValueOf(Blob{}), 0))
+	assert.True(t, containsBlob(reflect.ValueOf(&Blob{}), 0))
+	assert.True(t, containsBlob(reflect.ValueOf(map[string]interface{}{"file": Blob{}}), 0))
+	assert.True(t, containsBlob(reflect.ValueOf(ReportOutput{}), 0))
+
+	assert.False(t, containsBlob(reflect.ValueOf(nil), 0))
+	assert.False(t, containsBlob(reflect.ValueOf((*Blob)(nil)), 0))
+	assert.False(t, containsBlob(reflect.ValueOf([]byte("data")), 0))
+	assert.False(t, containsBlob(reflect.ValueOf(map[string]interface{}{"summary": "done"}), 0))
+	assert.False(t, containsBlob(reflect.ValueOf(ReportOutput{}.Charts), 0))
+}
@@ -0,0 +1,163 @@
+package inferable
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/lru"
+)
+
+// DefaultCacheMaxEntries is the number of results a tool cache holds unless
+// configured otherwise.
+const DefaultCacheMaxEntries = 1000
+
+// CacheConfig enables caching of a tool's results, for tools that are pure
+// lookups. Calls with the same input, and the same values of the selected
+// context fields, resolve with the cached result rather than calling the
+// tool. Only resolutions are cached.
+type CacheConfig struct {
+	// How long a result is cached for. Required.
+	TTL time.Duration
+	// Most results cached, evicting the least recently used. Defaults to
+	// DefaultCacheMaxEntries.
+	MaxEntries int
+	// Fields of ContextInput that the result depends on, and so are part of
+	// the cache key, e.g. "authContext.userId" or "runContext". By default
+	// results are shared across all callers.
+	KeyContext []string
+}
+
+// resultCache caches the results of a tool.
+type resultCache struct {
+	ttl        time.Duration
+	keyContext [][]string
+	results    *lru.Cache[cachedResult]
+}
+
+type cachedResult struct {
+	result    Result
+	expiresAt time.Time
+}
+
+var cacheContextFields = map[string]bool{
+	"authContext": true,
+	"runContext":  true,
+	"approved":    true,
+}
+
+func newResultCache(name string, config CacheConfig) (*resultCache, error) {
+	if config.TTL <= 0 {
+		return nil, fmt.Errorf("cache TTL for tool '%s' must be positive", name)
+	}
+
+	maxEntries := config.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if maxEntries < 0 {
+		return nil, fmt.Errorf("cache max entries for tool '%s' must be positive", name)
+	}
+
+	keyContext := make([][]string, len(config.KeyContext))
+	for i, field := range config.KeyContext {
+		path := strings.Split(field, ".")
+		if !cacheContextFields[path[0]] {
+			return nil, fmt.Errorf("cache key context '%s' for tool '%s' must start with authContext, runContext or approved", field, name)
+		}
+		keyContext[i] = path
+	}
+
+	return &resultCache{
+		ttl:        config.TTL,
+		keyContext: keyContext,
+		results:    lru.New[cachedResult](maxEntries),
+	}, nil
+}
+
+// middleware serves calls from the cache, calling next on a miss and caching
+// the result if it resolved.
+func (c *resultCache) middleware(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, call ToolCall) (Result, error) {
+		key, err := c.key(call)
+		if err != nil {
+			// Inputs that can't be keyed aren't cached
+			return next(ctx, call)
+		}
+
+		if cached, ok := c.results.Get(key); ok {
+			if time.Now().Before(cached.expiresAt) {
+				result := cached.result
+				result.CacheHit = true
+				result.ExecutionTime = 0
+				return result, nil
+			}
+			c.results.Remove(key)
+		}
+
+		result, err := next(ctx, call)
+		if err == nil && result.Type == ResultTypeResolution {
+			c.results.Add(key, cachedResult{result: result, expiresAt: time.Now().Add(c.ttl)})
+		}
+
+		return result, err
+	}
+}
+
+// key derives the cache key from the canonical form of the input and the
+// selected context fields.
+func (c *resultCache) key(call ToolCall) (string, error) {
+	input := json.RawMessage(call.Input)
+	if len(input) == 0 {
+		input = json.RawMessage("null")
+	}
+
+	// Decoding and encoding again sorts object keys and drops whitespace
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(input)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", err
+	}
+
+	parts := []interface{}{decoded}
+
+	if len(c.keyContext) > 0 {
+		contextJSON, err := json.Marshal(call.Context)
+		if err != nil {
+			return "", err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(contextJSON, &fields); err != nil {
+			return "", err
+		}
+
+		for _, path := range c.keyContext {
+			parts = append(parts, lookupPath(fields, path))
+		}
+	}
+
+	canonical, err := json.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lookupPath returns the value at a path of object keys, or nil if missing.
+func lookupPath(value interface{}, path []string) interface{} {
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
@@ -0,0 +1,140 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestToolCache(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	var lookups atomic.Int64
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "rate",
+		Func: func(input struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}, ctx ContextInput) (string, error) {
+			lookups.Add(1)
+			if input.From == "" {
+				return "", fmt.Errorf("from is required")
+			}
+			return input.From + input.To, nil
+		},
+		Cache: &CacheConfig{TTL: time.Minute},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	first := server.Enqueue(t, "rate", map[string]string{"from": "USD", "to": "EUR"})
+	require.NoError(t, i.Tools.poll())
+	// Same input, different key order
+	second := server.Enqueue(t, "rate", map[string]string{"to": "EUR", "from": "USD"})
+	require.NoError(t, i.Tools.poll())
+
+	assert.Equal(t, int64(1), lookups.Load())
+
+	result, _ := server.Result(first)
+	assert.JSONEq(t, `"USDEUR"`, string(result.Result))
+	assert.Nil(t, result.Meta["cacheHit"])
+
+	result, _ = server.Result(second)
+	assert.JSONEq(t, `"USDEUR"`, string(result.Result))
+	assert.Equal(t, true, result.Meta["cacheHit"])
+
+	// Different input and rejections aren't served from the cache
+	ctx := context.Background()
+	_, err = i.Tools.Invoke(ctx, "rate", json.RawMessage(`{"from":"GBP","to":"EUR"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lookups.Load())
+
+	for n := 0; n < 2; n++ {
+		result, err := i.Tools.Invoke(ctx, "rate", json.RawMessage(`{"to":"EUR"}`), ContextInput{})
+		require.NoError(t, err)
+		assert.Equal(t, ResultTypeRejection, result.Type)
+	}
+	assert.Equal(t, int64(4), lookups.Load())
+}
+
+func TestToolCacheKeyContext(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "https://api.inferable.ai",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	var denied atomic.Int64
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "catalog",
+		Func: func(input EchoInput, ctx ContextInput) string {
+			calls.Add(1)
+			return input.Input
+		},
+		Cache: &CacheConfig{TTL: 50 * time.Millisecond, KeyContext: []string{"authContext.userId"}},
+		Middleware: []Middleware{func(next ToolHandler) ToolHandler {
+			return func(ctx context.Context, call ToolCall) (Result, error) {
+				if call.Context.AuthContext == nil {
+					denied.Add(1)
+					return Result{Value: "unauthorized", Type: ResultTypeRejection}, nil
+				}
+				return next(ctx, call)
+			}
+		}},
+	}))
+
+	invoke := func(userID string) Result {
+		contextInput := ContextInput{}
+		if userID != "" {
+			contextInput.AuthContext = map[string]interface{}{"userId": userID, "session": time.Now().String()}
+		}
+		result, err := i.Tools.Invoke(context.Background(), "catalog", json.RawMessage(`{"Input":"shoes"}`), contextInput)
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.False(t, invoke("alice").CacheHit)
+	assert.True(t, invoke("alice").CacheHit, "other context fields don't affect the key")
+	assert.False(t, invoke("bob").CacheHit)
+	assert.Equal(t, int64(2), calls.Load())
+
+	// Middleware runs before the cache
+	assert.Equal(t, ResultTypeRejection, invoke("").Type)
+	assert.Equal(t, int64(1), denied.Load())
+
+	// Results expire after the TTL
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, invoke("alice").CacheHit)
+	assert.Equal(t, int64(3), calls.Load())
+}
+
+func TestToolCacheConfigValidation(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "https://api.inferable.ai",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	assert.Error(t, i.Tools.Register(Tool{Name: "noTTL", Func: echo, Cache: &CacheConfig{}}))
+	assert.Error(t, i.Tools.Register(Tool{Name: "negative", Func: echo, Cache: &CacheConfig{TTL: time.Second, MaxEntries: -1}}))
+	assert.Error(t, i.Tools.Register(Tool{Name: "badContext", Func: echo, Cache: &CacheConfig{TTL: time.Second, KeyContext: []string{"userId"}}}))
+	assert.NoError(t, i.Tools.Register(Tool{Name: "valid", Func: echo, Cache: &CacheConfig{TTL: time.Second, KeyContext: []string{"runContext", "approved"}}}))
+}
@@ -0,0 +1,146 @@
+package inferable
+
+import (
+	"fmt"
+)
+
+// Cluster is an additional cluster attached to an Inferable instance with
+// AttachCluster. It polls for the same tools as the instance it was attached
+// to, using its own secret, endpoint and cluster ID.
+type Cluster struct {
+	primary   *Inferable
+	inferable *Inferable
+}
+
+// AttachCluster connects an additional cluster to the tools registered on i,
+// so that a single process can serve several clusters. Tools registered on i
+// (including those registered later) are made available to every attached
+// cluster, while polling, metrics and shutdown are managed per cluster.
+//
+// The options must include a secret, either APISecret or SecretProvider.
+// The machine ID is shared with i unless set.
+//
+// Example:
+//
+//	customer, err := client.AttachCluster(inferable.InferableOptions{
+//	    APISecret: "CUSTOMER_API_SECRET",
+//	})
+//
+//	customer.Listen()
+//	defer customer.Unlisten()
+func (i *Inferable) AttachCluster(options InferableOptions) (*Cluster, error) {
+	if options.APISecret == "" && options.SecretProvider == nil {
+		return nil, fmt.Errorf("attached clusters require an APISecret or SecretProvider")
+	}
+
+	if options.MachineID == "" {
+		options.MachineID = i.machineID
+	}
+
+	inferable, err := New(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach cluster: %v", err)
+	}
+
+	c := &Cluster{
+		primary:   i,
+		inferable: inferable,
+	}
+
+	// Replace the default agent with one sharing the primary's tools
+	inferable.services = make(map[string]*pollingAgent)
+	inferable.Tools = c.mirror(i.Tools)
+
+	i.clustersMu.Lock()
+	i.clusters = append(i.clusters, c)
+	i.clustersMu.Unlock()
+
+	return c, nil
+}
+
+// Clusters returns the clusters attached with AttachCluster.
+func (i *Inferable) Clusters() []*Cluster {
+	i.clustersMu.Lock()
+	defer i.clustersMu.Unlock()
+
+	return append([]*Cluster{}, i.clusters...)
+}
+
+// ID returns the cluster's ID, registering the machine if it hasn't been
+// registered yet.
+func (c *Cluster) ID() (string, error) {
+	return c.inferable.getClusterId()
+}
+
+// Listen starts polling the cluster for every service of the primary instance
+// that has tools registered. Services created or given their first tools
+// after Listen are picked up by calling Listen again.
+func (c *Cluster) Listen() error {
+	started := 0
+	for _, source := range c.primary.agents() {
+		agent := c.mirror(source)
+		if agent.isPolling() {
+			started++
+			continue
+		}
+
+		if len(agent.toolList()) == 0 {
+			continue
+		}
+
+		if err := agent.Listen(); err != nil {
+			return fmt.Errorf("failed to listen for service '%s': %v", agent.name, err)
+		}
+		started++
+	}
+
+	if started == 0 {
+		return fmt.Errorf("cannot listen without any registered tools")
+	}
+
+	return nil
+}
+
+// Unlisten stops polling the cluster. Other clusters, and the primary
+// instance, are unaffected.
+func (c *Cluster) Unlisten() {
+	for _, agent := range c.inferable.agents() {
+		agent.Unlisten()
+	}
+}
+
+// Metrics returns the combined metrics of the cluster's services.
+func (c *Cluster) Metrics() AgentMetrics {
+	metrics := AgentMetrics{}
+	for _, agent := range c.inferable.agents() {
+		metrics = metrics.add(agent.Metrics())
+	}
+	return metrics
+}
+
+// mirror returns the cluster's agent for a service of the primary instance,
+// creating it if needed. The agent shares the source's tool registry and
+// idempotency store.
+func (c *Cluster) mirror(source *pollingAgent) *pollingAgent {
+	c.inferable.servicesMu.Lock()
+	defer c.inferable.servicesMu.Unlock()
+
+	if agent, exists := c.inferable.services[source.name]; exists {
+		return agent
+	}
+
+	agent := &pollingAgent{
+		registry:          source.registry,
+		inferable:         c.inferable,
+		name:              source.name,
+		maxConcurrency:    source.maxConcurrency,
+		pollWaitTime:      source.pollWaitTime,
+		idempotencyStore:  source.idempotencyStore,
+		heartbeatInterval: source.heartbeatInterval,
+		resultLimit:       source.resultLimit,
+	}
+	source.registry.attach(agent)
+	c.inferable.services[source.name] = agent
+
+	return agent
+}
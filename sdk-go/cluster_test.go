@@ -0,0 +1,88 @@
+package inferable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestAttachCluster(t *testing.T) {
+	primaryServer := inferabletest.NewServer(t)
+	customerServer := inferabletest.NewServer(t)
+	customerServer.SetSecret("sk_customer")
+
+	i, err := New(InferableOptions{
+		APIEndpoint:  primaryServer.URL,
+		APISecret:    primaryServer.Secret,
+		MachineID:    "shared-machine",
+		PollWaitTime: 1,
+	})
+	require.NoError(t, err)
+
+	_, err = i.AttachCluster(InferableOptions{APIEndpoint: customerServer.URL})
+	assert.Error(t, err, "a secret is required")
+
+	customer, err := i.AttachCluster(InferableOptions{
+		APIEndpoint: customerServer.URL,
+		APISecret:   "sk_customer",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*Cluster{customer}, i.Clusters())
+
+	assert.Error(t, customer.Listen(), "there are no tools yet")
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	store := NewMemoryIdempotencyStore(10)
+	reporting, err := i.Service("reporting", ServiceOptions{IdempotencyStore: store})
+	require.NoError(t, err)
+	require.NoError(t, reporting.Register(Tool{Name: "reverse", Func: reverse}))
+
+	require.NoError(t, i.Tools.Listen())
+	defer i.Tools.Unlisten()
+	require.NoError(t, customer.Listen())
+	defer customer.Unlisten()
+
+	// Services keep their idempotency stores in every cluster
+	assert.Same(t, store, customer.inferable.services["reporting"].idempotencyStore)
+	assert.Same(t, i.Tools.idempotencyStore, customer.inferable.services[DefaultServiceName].idempotencyStore)
+
+	id, err := customer.ID()
+	require.NoError(t, err)
+	assert.Equal(t, customerServer.ClusterID, id)
+
+	// Both clusters are served by the shared tools
+	result := primaryServer.WaitForResult(t, primaryServer.Enqueue(t, "echo", EchoInput{Input: "primary"}))
+	assert.JSONEq(t, `"primary"`, string(result.Result))
+	result = customerServer.WaitForResult(t, customerServer.Enqueue(t, "echo", EchoInput{Input: "customer"}))
+	assert.JSONEq(t, `"customer"`, string(result.Result))
+	result = customerServer.WaitForResult(t, customerServer.Enqueue(t, "reverse", ReverseInput{Input: "abc"}))
+	assert.JSONEq(t, `"cba"`, string(result.Result))
+
+	// Tools registered later are registered with every listening cluster
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "shout",
+		Func: func(input EchoInput, ctx ContextInput) string { return input.Input + "!" },
+	}))
+	_, ok := customerServer.Tool("shout")
+	assert.True(t, ok)
+	_, ok = primaryServer.Tool("shout")
+	assert.True(t, ok)
+
+	metrics := customer.Metrics()
+	assert.Equal(t, int64(2), metrics.Resolutions)
+	assert.Equal(t, int64(2), metrics.Jobs())
+	assert.False(t, metrics.LastPoll.IsZero())
+	assert.Equal(t, int64(1), i.Tools.Metrics().Resolutions)
+
+	// Shutting down one cluster leaves the other polling
+	customer.Unlisten()
+	for _, agent := range customer.inferable.agents() {
+		assert.False(t, agent.isPolling())
+	}
+	assert.True(t, i.Tools.isPolling())
+	result = primaryServer.WaitForResult(t, primaryServer.Enqueue(t, "echo", EchoInput{Input: "still polling"}))
+	assert.JSONEq(t, `"still polling"`, string(result.Result))
+}
@@ -0,0 +1,50 @@
+// Command inferable-gen generates registration code for tools, so that they
+// are registered with precomputed schemas and called without reflection.
+//
+// Tools are annotated with an //inferable:tool directive in the doc comment
+// of a package level function. The rest of the doc comment becomes the tool's
+// description, and the tool is named after the function unless a name is
+// given:
+//
+//	// lookupWeather returns the weather forecast for a city.
+//	//
+//	//inferable:tool name=GetWeather
+//	func lookupWeather(input WeatherInput, ctx inferable.ContextInput) (Forecast, error) {
+//
+// Run it with go generate from the package's directory:
+//
+//	//go:generate go run github.com/inferablehq/inferable/sdk-go/cmd/inferable-gen
+//
+// The generated file declares a function returning the tools, ready to be
+// registered:
+//
+//	for _, tool := range InferableTools() {
+//	    client.Tools.Register(tool)
+//	}
+//
+// Schemas are computed by the SDK itself, by briefly adding a test to the
+// package and running it, so they are identical to those Register reflects.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const (
+	defaultOutput = "inferable_tools.go"
+	defaultFunc   = "InferableTools"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package to generate tools for")
+	output := flag.String("output", defaultOutput, "name of the generated file, in the package directory")
+	funcName := flag.String("func", defaultFunc, "name of the generated function returning the tools")
+	flag.Parse()
+
+	if err := generate(*dir, *output, *funcName); err != nil {
+		fmt.Fprintf(os.Stderr, "inferable-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
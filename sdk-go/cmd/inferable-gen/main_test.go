@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePackage(t *testing.T) {
+	pkgName, tools, imports, err := parsePackage("testdata/weather", defaultOutput)
+	require.NoError(t, err)
+
+	assert.Equal(t, "weather", pkgName)
+	assert.Empty(t, imports)
+	assert.Equal(t, []tool{
+		{
+			Name:        "GetWeather",
+			Func:        "lookupWeather",
+			Description: "lookupWeather returns the weather forecast for a city.",
+			InputType:   "WeatherInput",
+		},
+		{
+			Name:      "alerts",
+			Func:      "alerts",
+			InputType: "*AlertInput",
+		},
+		{
+			Name:        "cities",
+			Func:        "cities",
+			Description: "cities lists the cities with forecasts in a country.",
+			InputType:   "string",
+		},
+	}, tools)
+}
+
+func TestParsePackageErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{
+			name:   "method",
+			source: "type s struct{}\n\n//inferable:tool\nfunc (s) tool(input struct{}, ctx struct{}) string { return \"\" }\n",
+			err:    "tool 'tool' must be a function, not a method",
+		},
+		{
+			name:   "arguments",
+			source: "//inferable:tool\nfunc tool(input struct{}) string { return \"\" }\n",
+			err:    "tool 'tool' must have exactly two arguments",
+		},
+		{
+			name:   "no result",
+			source: "//inferable:tool\nfunc tool(input, ctx struct{}) {}\n",
+			err:    "tool 'tool' must return a value",
+		},
+		{
+			name:   "unknown option",
+			source: "//inferable:tool title=Tool\nfunc tool(input, ctx struct{}) string { return \"\" }\n",
+			err:    "unknown //inferable:tool option 'title'",
+		},
+		{
+			name:   "duplicate",
+			source: "//inferable:tool name=a\nfunc a1(input, ctx struct{}) string { return \"\" }\n\n//inferable:tool name=a\nfunc a2(input, ctx struct{}) string { return \"\" }\n",
+			err:    "tool 'a' is declared more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "tools.go"), []byte("package tools\n\n"+tt.source), 0644))
+
+			_, _, _, err := parsePackage(dir, defaultOutput)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestParsePackageImports(t *testing.T) {
+	dir := t.TempDir()
+	source := `package tools
+
+import (
+	"net/url"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//inferable:tool
+func parse(input *url.URL, ctx struct{}) string { return "" }
+
+//inferable:tool
+func node(input yaml.Node, ctx struct{}) string { return "" }
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tools.go"), []byte(source), 0644))
+
+	_, _, imports, err := parsePackage(dir, defaultOutput)
+	require.NoError(t, err)
+	assert.Equal(t, []importSpec{
+		{Path: "gopkg.in/yaml.v3", Name: "yaml"},
+		{Path: "net/url"},
+	}, imports)
+}
+
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs a generated package")
+	}
+
+	// The package must be inside the module to resolve the SDK
+	dir, err := os.MkdirTemp("testdata", "_generate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"weather.go", "weather_test.go"} {
+		source, err := os.ReadFile(filepath.Join("testdata/weather", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), source, 0644))
+	}
+
+	require.NoError(t, generate(dir, defaultOutput, defaultFunc))
+
+	generated, err := os.ReadFile(filepath.Join(dir, defaultOutput))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), header)
+	assert.Contains(t, string(generated), `Name:        "GetWeather"`)
+	assert.Contains(t, string(generated), `"required": [`)
+	assert.NoFileExists(t, filepath.Join(dir, schemasTest))
+
+	// The generated tools register and run without reflection
+	cmd := exec.Command("go", "test", "-count=1", "-run", "^TestGeneratedTools$", ".")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	// Regenerating overwrites the previous output, but not other files
+	require.NoError(t, generate(dir, defaultOutput, defaultFunc))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.go"), []byte("package weather\n"), 0644))
+	err = generate(dir, "notes.go", defaultFunc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to overwrite")
+}
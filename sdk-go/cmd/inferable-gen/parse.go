@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const directive = "//inferable:tool"
+
+// tool is an annotated tool function.
+type tool struct {
+	// Name the tool is registered with
+	Name string
+	// Name of the function implementing it
+	Func        string
+	Description string
+	// Source of the function's input type, e.g. WeatherInput or *geo.Point
+	InputType string
+}
+
+// importSpec is an import needed by the generated code.
+type importSpec struct {
+	Name string
+	Path string
+}
+
+// parsePackage finds the annotated tool functions in the package in dir,
+// skipping test files and the generated file itself.
+func parsePackage(dir, output string) (string, []tool, []importSpec, error) {
+	fset := token.NewFileSet()
+	filter := func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != output
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to parse package: %v", err)
+	}
+	if len(pkgs) != 1 {
+		return "", nil, nil, fmt.Errorf("expected one package in '%s', found %d", dir, len(pkgs))
+	}
+
+	var pkgName string
+	tools := []tool{}
+	imports := map[string]importSpec{}
+
+	for name, pkg := range pkgs {
+		pkgName = name
+
+		// Sort the files so that the generated code is deterministic
+		filenames := make([]string, 0, len(pkg.Files))
+		for filename := range pkg.Files {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+
+		for _, filename := range filenames {
+			file := pkg.Files[filename]
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+
+				toolName, annotated, err := parseDirective(fn)
+				if err != nil {
+					return "", nil, nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+				}
+				if !annotated {
+					continue
+				}
+
+				t, err := parseTool(fset, file, fn, toolName, imports)
+				if err != nil {
+					return "", nil, nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+				}
+				tools = append(tools, t)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, t := range tools {
+		if seen[t.Name] {
+			return "", nil, nil, fmt.Errorf("tool '%s' is declared more than once", t.Name)
+		}
+		seen[t.Name] = true
+	}
+
+	importList := make([]importSpec, 0, len(imports))
+	for _, spec := range imports {
+		importList = append(importList, spec)
+	}
+	sort.Slice(importList, func(i, j int) bool { return importList[i].Path < importList[j].Path })
+
+	return pkgName, tools, importList, nil
+}
+
+// parseDirective returns the tool name given by the function's directive, if
+// it has one.
+func parseDirective(fn *ast.FuncDecl) (string, bool, error) {
+	if fn.Doc == nil {
+		return "", false, nil
+	}
+
+	for _, comment := range fn.Doc.List {
+		if comment.Text != directive && !strings.HasPrefix(comment.Text, directive+" ") {
+			continue
+		}
+
+		name := fn.Name.Name
+		for _, option := range strings.Fields(strings.TrimPrefix(comment.Text, directive)) {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "name":
+				if value == "" {
+					return "", false, fmt.Errorf("tool name must not be empty")
+				}
+				name = value
+			default:
+				return "", false, fmt.Errorf("unknown %s option '%s'", directive, key)
+			}
+		}
+
+		return name, true, nil
+	}
+
+	return "", false, nil
+}
+
+func parseTool(fset *token.FileSet, file *ast.File, fn *ast.FuncDecl, name string, imports map[string]importSpec) (tool, error) {
+	if fn.Recv != nil {
+		return tool{}, fmt.Errorf("tool '%s' must be a function, not a method", name)
+	}
+	if fn.Type.TypeParams != nil {
+		return tool{}, fmt.Errorf("tool '%s' must not be generic", name)
+	}
+
+	params := fieldTypes(fn.Type.Params)
+	if len(params) != 2 {
+		return tool{}, fmt.Errorf("tool '%s' must have exactly two arguments", name)
+	}
+	if len(fieldTypes(fn.Type.Results)) == 0 {
+		return tool{}, fmt.Errorf("tool '%s' must return a value", name)
+	}
+
+	var inputType bytes.Buffer
+	if err := printer.Fprint(&inputType, fset, params[0]); err != nil {
+		return tool{}, fmt.Errorf("failed to print input type of tool '%s': %v", name, err)
+	}
+
+	// Import the packages the input type refers to
+	var importErr error
+	ast.Inspect(params[0], func(n ast.Node) bool {
+		selector, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := selector.X.(*ast.Ident); ok {
+			spec, found := findImport(file, ident.Name)
+			if !found {
+				importErr = fmt.Errorf("tool '%s' refers to unknown package '%s'", name, ident.Name)
+				return false
+			}
+			imports[spec.Path] = spec
+		}
+		return false
+	})
+	if importErr != nil {
+		return tool{}, importErr
+	}
+
+	return tool{
+		Name:        name,
+		Func:        fn.Name.Name,
+		Description: strings.TrimSpace(fn.Doc.Text()),
+		InputType:   inputType.String(),
+	}, nil
+}
+
+// fieldTypes returns the type of every parameter or result in a list, where
+// a, b int counts as two.
+func fieldTypes(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+
+	types := []ast.Expr{}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// findImport returns the import of a file that a package name refers to.
+func findImport(file *ast.File, name string) (importSpec, bool) {
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if spec.Name != nil {
+			if spec.Name.Name == name {
+				return importSpec{Name: name, Path: importPath}, true
+			}
+			continue
+		}
+
+		if packageName(importPath) == name {
+			spec := importSpec{Path: importPath}
+			if path.Base(importPath) != name {
+				spec.Name = name
+			}
+			return spec, true
+		}
+	}
+
+	return importSpec{}, false
+}
+
+// packageName guesses the name of a package from its import path, ignoring
+// major version suffixes and gopkg.in versions.
+func packageName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	return strings.TrimPrefix(name, "go-")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const header = "// Code generated by inferable-gen. DO NOT EDIT."
+
+var toolsTemplate = template.Must(template.New("tools").Parse(header + `
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+
+	inferable "{{.SDK}}"
+{{- range .Imports}}
+	{{.Name}} {{printf "%q" .Path}}
+{{- end}}
+)
+
+// {{.Func}} returns the tools annotated with //inferable:tool, with
+// precomputed schemas, ready to be registered.
+func {{.Func}}() []inferable.Tool {
+	return []inferable.Tool{
+{{- range .Tools}}
+		{
+			Name: {{printf "%q" .Name}},
+{{- if .Description}}
+			Description: {{printf "%q" .Description}},
+{{- end}}
+			Func: {{.Func}},
+			Generated: &inferable.GeneratedTool{
+				Schema: json.RawMessage({{.Schema}}),
+{{- if .OutputSchema}}
+				OutputSchema: json.RawMessage({{.OutputSchema}}),
+{{- end}}
+				Call: func(input json.RawMessage, ctx inferable.ContextInput) inferable.Result {
+					var decoded {{.InputType}}
+					if err := ctx.DecodeInput(input, &decoded); err != nil {
+						return inferable.NewResult(nil, err)
+					}
+					return inferable.NewResult({{.Func}}(decoded, ctx))
+				},
+			},
+		},
+{{- end}}
+	}
+}
+`))
+
+// stubTemplate keeps the package compiling while schemas are computed, in
+// case the previously generated file refers to tools that have changed.
+var stubTemplate = template.Must(template.New("stub").Parse(header + `
+
+package {{.Package}}
+
+import inferable "{{.SDK}}"
+
+func {{.Func}}() []inferable.Tool {
+	return nil
+}
+`))
+
+// generate writes the registration code for the tools of the package in dir.
+func generate(dir, output, funcName string) error {
+	pkgName, tools, imports, err := parsePackage(dir, output)
+	if err != nil {
+		return err
+	}
+	if len(tools) == 0 {
+		return fmt.Errorf("no functions annotated with %s found", directive)
+	}
+
+	outputPath := filepath.Join(dir, output)
+	previous, err := os.ReadFile(outputPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read '%s': %v", outputPath, err)
+	}
+	if err == nil && !bytes.HasPrefix(previous, []byte(header)) {
+		return fmt.Errorf("refusing to overwrite '%s', which wasn't generated by inferable-gen", outputPath)
+	}
+
+	data := map[string]interface{}{
+		"Package": pkgName,
+		"SDK":     sdkImportPath,
+		"Func":    funcName,
+	}
+
+	stub, err := render(stubTemplate, data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, stub, 0644); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", outputPath, err)
+	}
+
+	toolSchemas, err := reflectSchemas(dir, pkgName, tools)
+	if err != nil {
+		restore(outputPath, previous)
+		return err
+	}
+
+	source, err := renderTools(pkgName, funcName, tools, imports, toolSchemas)
+	if err != nil {
+		restore(outputPath, previous)
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, source, 0644); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", outputPath, err)
+	}
+
+	return nil
+}
+
+// renderTools renders the generated file for the tools and their schemas.
+func renderTools(pkgName, funcName string, tools []tool, imports []importSpec, toolSchemas map[string]schemas) ([]byte, error) {
+	type renderedTool struct {
+		tool
+		Schema       string
+		OutputSchema string
+	}
+
+	rendered := make([]renderedTool, 0, len(tools))
+	for _, t := range tools {
+		s, ok := toolSchemas[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("no schema computed for tool '%s'", t.Name)
+		}
+
+		schema, err := schemaLiteral(s.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema for tool '%s': %v", t.Name, err)
+		}
+
+		outputSchema := ""
+		if len(s.OutputSchema) > 0 {
+			outputSchema, err = schemaLiteral(s.OutputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("invalid output schema for tool '%s': %v", t.Name, err)
+			}
+		}
+
+		rendered = append(rendered, renderedTool{tool: t, Schema: schema, OutputSchema: outputSchema})
+	}
+
+	return render(toolsTemplate, map[string]interface{}{
+		"Package": pkgName,
+		"SDK":     sdkImportPath,
+		"Func":    funcName,
+		"Imports": imports,
+		"Tools":   rendered,
+	})
+}
+
+// schemaLiteral formats a schema as an indented Go string literal, so that
+// changes to it are easy to review.
+func schemaLiteral(schema json.RawMessage) (string, error) {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, schema, "", "  "); err != nil {
+		return "", err
+	}
+
+	if strings.Contains(indented.String(), "`") {
+		return strconv.Quote(indented.String()), nil
+	}
+	return "`" + indented.String() + "`", nil
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var source bytes.Buffer
+	if err := tmpl.Execute(&source, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %v", tmpl.Name(), err)
+	}
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %v\n%s", tmpl.Name(), err, source.Bytes())
+	}
+
+	return formatted, nil
+}
+
+// restore puts back the previously generated file, or removes the stub if
+// there wasn't one.
+func restore(outputPath string, previous []byte) {
+	if previous == nil {
+		os.Remove(outputPath)
+		return
+	}
+	os.WriteFile(outputPath, previous, 0644)
+}
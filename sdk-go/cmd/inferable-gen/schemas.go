@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+const (
+	sdkImportPath = "github.com/inferablehq/inferable/sdk-go"
+	schemasTest   = "inferable_gen_schemas_test.go"
+	schemasEnv    = "INFERABLE_GEN_SCHEMAS"
+)
+
+// schemas are the precomputed schemas of a tool.
+type schemas struct {
+	Schema       json.RawMessage `json:"schema"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+}
+
+var schemasTestTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	inferable "{{.SDK}}"
+)
+
+func TestInferableGenSchemas(t *testing.T) {
+	type schemas struct {
+		Schema       json.RawMessage ` + "`json:\"schema\"`" + `
+		OutputSchema json.RawMessage ` + "`json:\"outputSchema,omitempty\"`" + `
+	}
+
+	tools := []struct {
+		name string
+		fn   interface{}
+	}{
+{{- range .Tools}}
+		{ {{printf "%q" .Name}}, {{.Func}} },
+{{- end}}
+	}
+
+	result := map[string]schemas{}
+	for _, tool := range tools {
+		generated, err := inferable.ReflectSchemas(tool.name, tool.fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[tool.name] = schemas{Schema: generated.Schema, OutputSchema: generated.OutputSchema}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(os.Getenv({{printf "%q" .Env}}), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+`))
+
+// reflectSchemas computes the schemas of the tools by adding a test to the
+// package that asks the SDK for them, and running it.
+func reflectSchemas(dir, pkgName string, tools []tool) (map[string]schemas, error) {
+	var source bytes.Buffer
+	err := schemasTestTemplate.Execute(&source, map[string]interface{}{
+		"Package": pkgName,
+		"SDK":     sdkImportPath,
+		"Tools":   tools,
+		"Env":     schemasEnv,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render schema test: %v", err)
+	}
+
+	testPath := filepath.Join(dir, schemasTest)
+	if err := os.WriteFile(testPath, source.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write schema test: %v", err)
+	}
+	defer os.Remove(testPath)
+
+	out, err := os.CreateTemp("", "inferable-gen-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema file: %v", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	cmd := exec.Command("go", "test", "-count=1", "-run", "^TestInferableGenSchemas$", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), schemasEnv+"="+out.Name())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to compute schemas: %v\n%s", err, output)
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schemas: %v", err)
+	}
+
+	result := map[string]schemas{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse schemas: %v", err)
+	}
+
+	return result, nil
+}
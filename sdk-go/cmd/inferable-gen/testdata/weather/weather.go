@@ -0,0 +1,56 @@
+package weather
+
+import (
+	"fmt"
+	"time"
+
+	inferable "github.com/inferablehq/inferable/sdk-go"
+)
+
+type Units string
+
+type WeatherInput struct {
+	City  string `json:"city"`
+	Days  int    `json:"days,omitempty"`
+	Units Units  `json:"units,omitempty"`
+}
+
+type Forecast struct {
+	City    string    `json:"city"`
+	Summary string    `json:"summary"`
+	Issued  time.Time `json:"issued"`
+}
+
+// lookupWeather returns the weather forecast for a city.
+//
+//inferable:tool name=GetWeather
+func lookupWeather(input WeatherInput, ctx inferable.ContextInput) (*Forecast, error) {
+	if input.City == "" {
+		return nil, fmt.Errorf("city is required")
+	}
+	return &Forecast{City: input.City, Summary: "sunny"}, nil
+}
+
+type AlertInput struct {
+	Since time.Time `json:"since"`
+}
+
+//inferable:tool
+func alerts(input *AlertInput, ctx inferable.ContextInput) ([]string, *inferable.Interrupt, error) {
+	if !ctx.Approved {
+		return nil, inferable.ApprovalInterrupt(), nil
+	}
+	return []string{"storm"}, nil, nil
+}
+
+// cities lists the cities with forecasts in a country.
+//
+//inferable:tool
+func cities(country string, ctx inferable.ContextInput) []string {
+	return []string{"London", "Manchester"}
+}
+
+// notATool isn't annotated.
+func notATool(input WeatherInput, ctx inferable.ContextInput) string {
+	return ""
+}
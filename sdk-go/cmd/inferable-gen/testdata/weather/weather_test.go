@@ -0,0 +1,61 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	inferable "github.com/inferablehq/inferable/sdk-go"
+)
+
+func TestGeneratedTools(t *testing.T) {
+	client, err := inferable.New(inferable.InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Tools.RegisterEnum(reflect.TypeOf(Units("")), "metric", "imperial"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tool := range InferableTools() {
+		if err := client.Tools.Register(tool); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := client.Tools.Invoke(context.Background(), "GetWeather", json.RawMessage(`{"city":"London"}`), inferable.ContextInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast, ok := result.Value.(*Forecast); result.Type != inferable.ResultTypeResolution || !ok || forecast.City != "London" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	result, _ = client.Tools.Invoke(context.Background(), "GetWeather", json.RawMessage(`{"city":1}`), inferable.ContextInput{})
+	if result.Type != inferable.ResultTypeRejection {
+		t.Fatalf("expected rejection, got %+v", result)
+	}
+
+	// Enums registered with the client are checked by the generated code
+	result, _ = client.Tools.Invoke(context.Background(), "GetWeather", json.RawMessage(`{"city":"London","units":"kelvin"}`), inferable.ContextInput{})
+	if result.Type != inferable.ResultTypeRejection || result.Value != `$.units: must be one of "metric", "imperial", got "kelvin"` {
+		t.Fatalf("expected rejection, got %+v", result)
+	}
+
+	result, _ = client.Tools.Invoke(context.Background(), "cities", json.RawMessage(`{"value":"UK"}`), inferable.ContextInput{})
+	if names, ok := result.Value.([]string); result.Type != inferable.ResultTypeResolution || !ok || len(names) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	result, _ = client.Tools.Invoke(context.Background(), "alerts", json.RawMessage(`{}`), inferable.ContextInput{})
+	if result.Type != inferable.ResultTypeInterrupt {
+		t.Fatalf("expected interrupt, got %+v", result)
+	}
+}
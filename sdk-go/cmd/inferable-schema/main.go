@@ -0,0 +1,84 @@
+// Command inferable-schema compares tool schema snapshots, as written by
+// WriteSchemaSnapshot or inferabletest.AssertSchemaSnapshot, and reports the
+// changes between them. It exits with status 1 if any change is breaking, so
+// it can guard CI against changes that would break running agents:
+//
+//	git show main:schemas.json > /tmp/schemas.json
+//	inferable-schema diff /tmp/schemas.json schemas.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/schemadiff"
+)
+
+const usage = `usage: inferable-schema diff [-breaking] OLD NEW
+
+Reports the changes between two tool schema snapshots. Exits with status 1
+if any of them is breaking.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "diff" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	breakingOnly := flags.Bool("breaking", false, "only report breaking changes")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	old, err := readSnapshot(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "inferable-schema: %v\n", err)
+		return 2
+	}
+	new, err := readSnapshot(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "inferable-schema: %v\n", err)
+		return 2
+	}
+
+	changes := schemadiff.Diff(old, new)
+	for _, change := range changes {
+		if *breakingOnly && !change.Breaking {
+			continue
+		}
+		fmt.Fprintln(stdout, change)
+	}
+
+	if schemadiff.HasBreaking(changes) {
+		return 1
+	}
+	return 0
+}
+
+func readSnapshot(path string) (schemadiff.Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema snapshot: %v", err)
+	}
+
+	snapshot, err := schemadiff.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return snapshot, nil
+}
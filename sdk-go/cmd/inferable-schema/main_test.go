@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	old := write("old.json", `{"echo":{"name":"echo","input":{"type":"object","properties":{"input":{"type":"string"}}}}}`)
+	added := write("added.json", `{
+		"echo":{"name":"echo","input":{"type":"object","properties":{"input":{"type":"string"}}}},
+		"ping":{"name":"ping","input":{"type":"object"}}
+	}`)
+	removed := write("removed.json", `{"echo":{"name":"echo","input":{"type":"object","properties":{}}}}`)
+	invalid := write("invalid.json", `not json`)
+
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+	}{
+		{"unchanged", []string{"diff", old, old}, 0, ""},
+		{"non-breaking", []string{"diff", old, added}, 0, "ping: tool added\n"},
+		{"breaking only", []string{"diff", "-breaking", old, added}, 0, ""},
+		{"breaking", []string{"diff", old, removed}, 1, "BREAKING echo input.input: property removed\n"},
+		{"invalid snapshot", []string{"diff", old, invalid}, 2, ""},
+		{"missing snapshot", []string{"diff", old, filepath.Join(dir, "missing.json")}, 2, ""},
+		{"missing arguments", []string{"diff", old}, 2, ""},
+		{"unknown command", []string{"check"}, 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.status, run(tt.args, &stdout, &stderr), stderr.String())
+			assert.Equal(t, tt.stdout, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package inferable
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	gopath "path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// AddGoComments reads the doc comments of the Go source files under path,
+// so that tools registered afterwards are described by them. Struct and field
+// comments become descriptions in the input and output schemas, and the
+// comment on a tool's function is used when Tool.Description is empty.
+// Descriptions given explicitly, including jsonschema struct tags, take
+// precedence.
+//
+// The base is the import path of the package at path, typically the module
+// path when path is the module root. The source files must be available at
+// runtime, so call it from the directory the code is built from.
+//
+// Example:
+//
+//	// In a module named github.com/acme/tools
+//	err := client.AddGoComments("github.com/acme/tools", "./")
+func (i *Inferable) AddGoComments(base, path string) error {
+	reflector := jsonschema.Reflector{}
+	if err := reflector.AddGoComments(base, path); err != nil {
+		return fmt.Errorf("failed to read comments from '%s': %v", path, err)
+	}
+
+	funcComments, err := extractFuncComments(base, path)
+	if err != nil {
+		return fmt.Errorf("failed to read comments from '%s': %v", path, err)
+	}
+
+	i.commentsMu.Lock()
+	defer i.commentsMu.Unlock()
+
+	if i.comments == nil {
+		i.comments = make(map[string]string)
+	}
+	for key, comment := range reflector.CommentMap {
+		i.comments[key] = comment
+	}
+	for key, comment := range funcComments {
+		i.comments[key] = comment
+	}
+
+	return nil
+}
+
+// commentMap returns a copy of the comments added with AddGoComments, keyed
+// by fully qualified type, field and function name.
+func (i *Inferable) commentMap() map[string]string {
+	i.commentsMu.Lock()
+	defer i.commentsMu.Unlock()
+
+	if len(i.comments) == 0 {
+		return nil
+	}
+
+	comments := make(map[string]string, len(i.comments))
+	for key, comment := range i.comments {
+		comments[key] = comment
+	}
+	return comments
+}
+
+// funcComment returns the doc comment of a tool function, or "" if it has
+// none or is a closure.
+func (i *Inferable) funcComment(fn interface{}) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return ""
+	}
+
+	runtimeFunc := runtime.FuncForPC(value.Pointer())
+	if runtimeFunc == nil {
+		return ""
+	}
+
+	// Method values are suffixed with -fm
+	name := strings.TrimSuffix(runtimeFunc.Name(), "-fm")
+
+	i.commentsMu.Lock()
+	defer i.commentsMu.Unlock()
+
+	return i.comments[name]
+}
+
+// extractFuncComments reads the doc comments of functions and methods, keyed
+// the way the runtime names them: pkg.Func, pkg.Type.Method and
+// pkg.(*Type).Method.
+func extractFuncComments(base, path string) (map[string]string, error) {
+	comments := make(map[string]string)
+	fset := token.NewFileSet()
+
+	err := filepath.Walk(path, func(dir string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+
+		pkg := gopath.Join(base, filepath.ToSlash(dir))
+		for _, p := range pkgs {
+			for _, file := range p.Files {
+				for _, decl := range file.Decls {
+					fn, ok := decl.(*ast.FuncDecl)
+					if !ok || fn.Doc == nil {
+						continue
+					}
+
+					name := fn.Name.Name
+					if fn.Recv != nil && len(fn.Recv.List) == 1 {
+						receiver := receiverName(fn.Recv.List[0].Type)
+						if receiver == "" {
+							continue
+						}
+						name = receiver + "." + name
+					}
+
+					comments[pkg+"."+name] = strings.TrimSpace(fn.Doc.Text())
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok {
+			return "(*" + ident.Name + ")"
+		}
+	}
+
+	// Generic receivers are named with their type arguments at runtime
+	return ""
+}
@@ -0,0 +1,85 @@
+package inferable
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// WeatherInput selects the location to report on.
+type WeatherInput struct {
+	// The city to look up, e.g. London
+	City string `json:"city"`
+	// Days to forecast
+	Days int `json:"days" jsonschema:"description=Number of days to forecast"`
+}
+
+// Forecast is the weather expected in a city.
+type Forecast struct {
+	// Temperature in degrees Celsius
+	Temperature float64 `json:"temperature"`
+}
+
+// lookupWeather returns the weather forecast for a city.
+func lookupWeather(input WeatherInput, ctx ContextInput) Forecast {
+	return Forecast{}
+}
+
+type weatherService struct{}
+
+// Forecast returns the forecast using the service's provider.
+func (w *weatherService) Forecast(input WeatherInput, ctx ContextInput) Forecast {
+	return Forecast{}
+}
+
+func TestAddGoComments(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "https://api.inferable.ai",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	// Without comments nothing is described
+	require.NoError(t, i.Tools.Register(Tool{Name: "before", Func: lookupWeather}))
+	before, _ := i.Tools.getTool("before")
+	assert.Empty(t, before.Description)
+
+	require.NoError(t, i.AddGoComments("github.com/inferablehq/inferable/sdk-go", "./"))
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "weather", Func: lookupWeather}))
+	require.NoError(t, i.Tools.Register(Tool{Name: "method", Func: (&weatherService{}).Forecast}))
+	require.NoError(t, i.Tools.Register(Tool{Name: "explicit", Func: lookupWeather, Description: "Explicit description"}))
+
+	weather, _ := i.Tools.getTool("weather")
+	assert.Equal(t, "lookupWeather returns the weather forecast for a city.", weather.Description)
+
+	schemaJSON, err := json.Marshal(weather.schema)
+	require.NoError(t, err)
+	var schema struct {
+		Description string `json:"description"`
+		Properties  map[string]struct {
+			Description string `json:"description"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(schemaJSON, &schema))
+	assert.Equal(t, "WeatherInput selects the location to report on.", schema.Description)
+	assert.Equal(t, "The city to look up, e.g. London", schema.Properties["city"].Description)
+	// Struct tags take precedence over comments
+	assert.Equal(t, "Number of days to forecast", schema.Properties["days"].Description)
+
+	outputJSON, err := json.Marshal(weather.outputSchema)
+	require.NoError(t, err)
+	assert.Contains(t, string(outputJSON), "Temperature in degrees Celsius")
+
+	method, _ := i.Tools.getTool("method")
+	assert.Equal(t, "Forecast returns the forecast using the service's provider.", method.Description)
+
+	explicit, _ := i.Tools.getTool("explicit")
+	assert.Equal(t, "Explicit description", explicit.Description)
+
+	assert.Error(t, i.AddGoComments("github.com/inferablehq/inferable/sdk-go", "./does-not-exist"))
+}
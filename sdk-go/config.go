@@ -0,0 +1,179 @@
+package inferable
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables consulted by New for options that aren't set
+// explicitly. Each variable may instead be given with a _FILE suffix naming a
+// file that holds the value, as used for Docker secrets.
+const (
+	EnvAPISecret     = "INFERABLE_API_SECRET"
+	EnvAPIEndpoint   = "INFERABLE_API_ENDPOINT"
+	EnvMachineID     = "INFERABLE_MACHINE_ID"
+	EnvMachineIDPath = "INFERABLE_MACHINE_ID_PATH"
+	EnvPollWaitTime  = "INFERABLE_POLL_WAIT_TIME"
+	EnvConfigFile    = "INFERABLE_CONFIG_FILE"
+)
+
+// fileEnvSuffix marks an environment variable that names a file holding the
+// value rather than the value itself.
+const fileEnvSuffix = "_FILE"
+
+// configFile is the YAML (or JSON) document referenced by
+// InferableOptions.ConfigFile or INFERABLE_CONFIG_FILE.
+type configFile struct {
+	APISecret     string `yaml:"apiSecret"`
+	APISecretFile string `yaml:"apiSecretFile"`
+	APIEndpoint   string `yaml:"apiEndpoint"`
+	MachineID     string `yaml:"machineId"`
+	MachineIDPath string `yaml:"machineIdPath"`
+	PollWaitTime  int    `yaml:"pollWaitTime"`
+}
+
+// resolveOptions fills unset options from the environment and then from the
+// config file, in that order of precedence.
+func resolveOptions(options InferableOptions) (InferableOptions, error) {
+	configPath := options.ConfigFile
+	if configPath == "" {
+		var err error
+		configPath, err = lookupEnv(EnvConfigFile)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	config := configFile{}
+	if configPath != "" {
+		var err error
+		config, err = loadConfigFile(configPath)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	stringOptions := []struct {
+		value      *string
+		env        string
+		fromConfig string
+	}{
+		{&options.APISecret, EnvAPISecret, config.APISecret},
+		{&options.APIEndpoint, EnvAPIEndpoint, config.APIEndpoint},
+		{&options.MachineID, EnvMachineID, config.MachineID},
+		{&options.MachineIDPath, EnvMachineIDPath, config.MachineIDPath},
+	}
+
+	for _, o := range stringOptions {
+		if *o.value != "" {
+			continue
+		}
+
+		value, err := lookupEnv(o.env)
+		if err != nil {
+			return options, err
+		}
+		if value == "" {
+			value = o.fromConfig
+		}
+		*o.value = value
+	}
+
+	if options.APISecret == "" && config.APISecretFile != "" {
+		secret, err := readValueFile(config.APISecretFile)
+		if err != nil {
+			return options, fmt.Errorf("failed to read apiSecretFile from config file: %v", err)
+		}
+		options.APISecret = secret
+	}
+
+	if options.PollWaitTime == 0 {
+		value, err := lookupEnv(EnvPollWaitTime)
+		if err != nil {
+			return options, err
+		}
+		if value != "" {
+			options.PollWaitTime, err = strconv.Atoi(value)
+			if err != nil {
+				return options, fmt.Errorf("invalid %s: %v", EnvPollWaitTime, err)
+			}
+		} else {
+			options.PollWaitTime = config.PollWaitTime
+		}
+	}
+
+	// A secret provider is validated when it is first consulted
+	if options.SecretProvider == nil {
+		options.APISecret = strings.TrimSpace(options.APISecret)
+		if err := validateAPISecret(options.APISecret); err != nil {
+			return options, err
+		}
+	}
+
+	return options, nil
+}
+
+// lookupEnv reads an environment variable, or the contents of the file named
+// by its _FILE counterpart.
+func lookupEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + fileEnvSuffix)
+
+	if value != "" && path != "" {
+		return "", fmt.Errorf("both %s and %s%s are set, only one may be used", name, name, fileEnvSuffix)
+	}
+
+	if path != "" {
+		contents, err := readValueFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s%s: %v", name, fileEnvSuffix, err)
+		}
+		return contents, nil
+	}
+
+	return strings.TrimSpace(value), nil
+}
+
+func readValueFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func loadConfigFile(path string) (configFile, error) {
+	config := configFile{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// JSON is a subset of YAML, so both formats are handled by the same decoder
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+func validateAPISecret(secret string) error {
+	if secret == "" {
+		return fmt.Errorf("API secret is required: set InferableOptions.APISecret, %s, %s%s or apiSecret in the config file", EnvAPISecret, EnvAPISecret, fileEnvSuffix)
+	}
+
+	if strings.ContainsFunc(secret, func(r rune) bool {
+		return unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return fmt.Errorf("API secret is malformed: it must not contain whitespace or control characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package inferable
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearConfigEnv isolates a test from configuration in the environment.
+func clearConfigEnv(t *testing.T) {
+	for _, name := range []string{EnvAPISecret, EnvAPIEndpoint, EnvMachineID, EnvMachineIDPath, EnvPollWaitTime, EnvConfigFile} {
+		t.Setenv(name, "")
+		t.Setenv(name+fileEnvSuffix, "")
+	}
+}
+
+func writeFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestResolveOptionsFromEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv(EnvAPISecret, "env-secret")
+	t.Setenv(EnvAPIEndpoint, "http://localhost:4000")
+	t.Setenv(EnvPollWaitTime, "5")
+
+	options, err := resolveOptions(InferableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "env-secret", options.APISecret)
+	assert.Equal(t, "http://localhost:4000", options.APIEndpoint)
+	assert.Equal(t, 5, options.PollWaitTime)
+
+	// Explicit options take precedence
+	options, err = resolveOptions(InferableOptions{APISecret: "explicit-secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "explicit-secret", options.APISecret)
+}
+
+func TestResolveOptionsFromFileEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv(EnvAPISecret+fileEnvSuffix, writeFile(t, "secret", "file-secret\n"))
+
+	options, err := resolveOptions(InferableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", options.APISecret)
+
+	t.Setenv(EnvAPISecret, "env-secret")
+	_, err = resolveOptions(InferableOptions{})
+	assert.ErrorContains(t, err, "only one may be used")
+}
+
+func TestResolveOptionsFromConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+
+	yamlPath := writeFile(t, "inferable.yaml", "apiSecret: yaml-secret\napiEndpoint: http://localhost:4000\npollWaitTime: 7\n")
+	options, err := resolveOptions(InferableOptions{ConfigFile: yamlPath})
+	require.NoError(t, err)
+	assert.Equal(t, "yaml-secret", options.APISecret)
+	assert.Equal(t, "http://localhost:4000", options.APIEndpoint)
+	assert.Equal(t, 7, options.PollWaitTime)
+
+	secretPath := writeFile(t, "secret", "json-secret")
+	jsonPath := writeFile(t, "inferable.json", `{"apiSecretFile": "`+secretPath+`"}`)
+	t.Setenv(EnvConfigFile, jsonPath)
+	options, err = resolveOptions(InferableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "json-secret", options.APISecret)
+
+	// The environment takes precedence over the config file
+	t.Setenv(EnvAPISecret, "env-secret")
+	options, err = resolveOptions(InferableOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "env-secret", options.APISecret)
+}
+
+func TestResolveOptionsInvalidSecret(t *testing.T) {
+	clearConfigEnv(t)
+
+	_, err := New(InferableOptions{})
+	assert.ErrorContains(t, err, EnvAPISecret)
+
+	_, err = New(InferableOptions{APISecret: "sk_abc def"})
+	assert.ErrorContains(t, err, "malformed")
+
+	_, err = New(InferableOptions{APISecret: "secret", ConfigFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.ErrorContains(t, err, "config file")
+}
@@ -0,0 +1,287 @@
+package inferable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// Enum is implemented by types with a fixed set of values, such as typed
+// string constants. Their schemas list the values, and inputs with other
+// values are rejected.
+//
+// Example:
+//
+//	type Priority string
+//
+//	const (
+//		Low  Priority = "low"
+//		High Priority = "high"
+//	)
+//
+//	func (Priority) EnumValues() []any {
+//		return []any{Low, High}
+//	}
+type Enum interface {
+	EnumValues() []interface{}
+}
+
+var enumInterfaceType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// RegisterEnum sets the values of an enum type, for types that can't
+// implement Enum, such as those from other packages. Like MapType, it
+// applies to tools registered afterwards.
+//
+// The type must be a string, number or boolean type, and the values must be
+// of the same kind. Fields omitted from an input are left unchecked, so
+// that optional fields may be omitted, but given values are always checked.
+//
+// Example:
+//
+//	err := client.Tools.RegisterEnum(reflect.TypeOf(orders.Status("")), orders.Pending, orders.Shipped)
+func (s *pollingAgent) RegisterEnum(t reflect.Type, values ...interface{}) error {
+	if t == nil || enumSchemaType(t.Kind()) == "" {
+		return fmt.Errorf("enum type must be a string, number or boolean type, got %v", t)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("enum '%s' must have at least one value", t)
+	}
+
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		v := reflect.ValueOf(value)
+		if !v.IsValid() || !sameEnumKind(v.Kind(), t.Kind()) {
+			return fmt.Errorf("enum '%s' value %v is not a %s", t, value, enumSchemaType(t.Kind()))
+		}
+		converted[i] = v.Convert(t).Interface()
+	}
+
+	s.typesMu.Lock()
+	defer s.typesMu.Unlock()
+
+	if s.enums == nil {
+		s.enums = make(map[reflect.Type][]interface{})
+	}
+	s.enums[t] = converted
+
+	return nil
+}
+
+// enumMap returns a copy of the enums registered with RegisterEnum.
+func (s *pollingAgent) enumMap() map[reflect.Type][]interface{} {
+	s.typesMu.Lock()
+	defer s.typesMu.Unlock()
+
+	enums := make(map[reflect.Type][]interface{}, len(s.enums))
+	for t, values := range s.enums {
+		enums[t] = values
+	}
+	return enums
+}
+
+// enumValues returns the values of an enum type, registered or from its
+// Enum implementation.
+func enumValues(t reflect.Type, enums map[reflect.Type][]interface{}) ([]interface{}, bool) {
+	if values, ok := enums[t]; ok {
+		return values, true
+	}
+
+	var enum Enum
+	switch {
+	case t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface:
+		return nil, false
+	case t.Implements(enumInterfaceType):
+		enum = reflect.Zero(t).Interface().(Enum)
+	case reflect.PtrTo(t).Implements(enumInterfaceType):
+		enum = reflect.New(t).Interface().(Enum)
+	default:
+		return nil, false
+	}
+
+	// Values of other types, such as untyped constants, are converted
+	values := enum.EnumValues()
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = value
+		if v := reflect.ValueOf(value); v.IsValid() && v.Type() != t && sameEnumKind(v.Kind(), t.Kind()) {
+			converted[i] = v.Convert(t).Interface()
+		}
+	}
+
+	return converted, true
+}
+
+// enumSchema returns the schema for an enum type, if it is one.
+func enumSchema(t reflect.Type, enums map[reflect.Type][]interface{}) *jsonschema.Schema {
+	values, ok := enumValues(t, enums)
+	if !ok {
+		return nil
+	}
+
+	return &jsonschema.Schema{
+		Type: enumSchemaType(t.Kind()),
+		Enum: values,
+	}
+}
+
+// enumSchemaType returns the JSON schema type of an enum kind, or an empty
+// string if the kind can't be an enum.
+func enumSchemaType(kind reflect.Kind) string {
+	switch {
+	case kind == reflect.String:
+		return "string"
+	case kind == reflect.Bool:
+		return "boolean"
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		return "number"
+	case isNumber(kind):
+		return "integer"
+	default:
+		return ""
+	}
+}
+
+// sameEnumKind reports whether values of one kind can be converted to
+// values of another as enum values.
+func sameEnumKind(a, b reflect.Kind) bool {
+	if isNumber(a) && isNumber(b) {
+		return true
+	}
+	return a == b && enumSchemaType(a) != ""
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+// checkEnums returns an error if a decoded value holds a value of an enum
+// type that isn't one of its values. raw is the JSON the value was decoded
+// from, nil where it was absent. Zero values are only checked if present.
+func checkEnums(v reflect.Value, raw json.RawMessage, path string, enums map[reflect.Type][]interface{}) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return checkEnums(v.Elem(), raw, path, enums)
+	}
+
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		raw = nil
+	}
+
+	if values, ok := enumValues(v.Type(), enums); ok {
+		if raw == nil && v.IsZero() {
+			return nil
+		}
+		for _, value := range values {
+			if value == v.Interface() {
+				return nil
+			}
+		}
+
+		options := make([]string, len(values))
+		for i, value := range values {
+			options[i] = encodeValue(value)
+		}
+		return fmt.Errorf("%s: must be one of %s, got %s", path, strings.Join(options, ", "), encodeValue(v.Interface()))
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		json.Unmarshal(raw, &fields)
+
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, embedded := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+
+			fieldPath := path + "." + name
+			fieldRaw := rawField(fields, name)
+			if embedded {
+				fieldPath = path
+				fieldRaw = raw
+			}
+			if err := checkEnums(v.Field(i), fieldRaw, fieldPath, enums); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		json.Unmarshal(raw, &items)
+
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprintf("%v", iter.Key())
+			if err := checkEnums(iter.Value(), items[key], path+"."+key, enums); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		json.Unmarshal(raw, &items)
+
+		for i := 0; i < v.Len(); i++ {
+			var item json.RawMessage
+			if i < len(items) {
+				item = items[i]
+			}
+			if err := checkEnums(v.Index(i), item, fmt.Sprintf("%s[%d]", path, i), enums); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rawField returns the JSON of a field in a decoded object, matching names
+// case-insensitively as encoding/json does.
+func rawField(fields map[string]json.RawMessage, name string) json.RawMessage {
+	if raw, ok := fields[name]; ok {
+		return raw
+	}
+	for key, raw := range fields {
+		if strings.EqualFold(key, name) {
+			return raw
+		}
+	}
+	return nil
+}
+
+// jsonFieldName returns the name of a struct field in JSON, and whether it
+// is an embedded struct whose fields are encoded in place of it.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	name, _, _ := strings.Cut(tag, ",")
+	if name != "" {
+		return name, false
+	}
+
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return field.Name, field.Anonymous && t.Kind() == reflect.Struct
+}
+
+func encodeValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,116 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Priority string
+
+const (
+	PriorityLow  Priority = "low"
+	PriorityHigh Priority = "high"
+)
+
+func (Priority) EnumValues() []any {
+	return []any{PriorityLow, PriorityHigh}
+}
+
+type Severity int
+
+func (*Severity) EnumValues() []any {
+	return []any{1, 2, 3}
+}
+
+type Region string
+
+type TicketInput struct {
+	Title    string     `json:"title"`
+	Priority Priority   `json:"priority,omitempty"`
+	Severity Severity   `json:"severity,omitempty"`
+	Regions  []Region   `json:"regions,omitempty"`
+	Parent   *Priority  `json:"parent,omitempty"`
+	Labels   []Priority `json:"-"`
+}
+
+func TestEnums(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, i.Tools.RegisterEnum(reflect.TypeOf(TicketInput{})), "enum type must be a string, number or boolean type")
+	assert.ErrorContains(t, i.Tools.RegisterEnum(reflect.TypeOf(Region(""))), "must have at least one value")
+	assert.ErrorContains(t, i.Tools.RegisterEnum(reflect.TypeOf(Region("")), "eu", 1), "enum 'inferable.Region' value 1 is not a string")
+	require.NoError(t, i.Tools.RegisterEnum(reflect.TypeOf(Region("")), "eu", "us"))
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "createTicket",
+		Func: func(input TicketInput, ctx ContextInput) string { return input.Title },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "escalate",
+		Func: func(priority Priority, ctx ContextInput) Priority { return priority },
+	}))
+
+	schema, err := json.Marshal(i.Tools.Tools()["createTicket"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string", "enum": ["low", "high"]}`, propertyJSON(t, schema, "priority"))
+	assert.JSONEq(t, `{"type": "integer", "enum": [1, 2, 3]}`, propertyJSON(t, schema, "severity"))
+	assert.JSONEq(t, `{"type": "array", "items": {"type": "string", "enum": ["eu", "us"]}}`, propertyJSON(t, schema, "regions"))
+
+	output, err := json.Marshal(i.Tools.Tools()["escalate"].outputSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "string", "enum": ["low", "high"]}`, string(output))
+
+	invoke := func(name, input string) Result {
+		result, err := i.Tools.Invoke(context.Background(), name, json.RawMessage(input), ContextInput{})
+		require.NoError(t, err)
+		return result
+	}
+
+	// Valid and omitted values are accepted
+	assert.Equal(t, ResultTypeResolution, invoke("createTicket", `{"title": "a", "priority": "high", "severity": 2, "regions": ["eu"], "parent": "low"}`).Type)
+	assert.Equal(t, ResultTypeResolution, invoke("createTicket", `{"title": "a"}`).Type)
+	assert.Equal(t, ResultTypeResolution, invoke("createTicket", `{"title": "a", "priority": null}`).Type)
+	assert.Equal(t, ResultTypeResolution, invoke("escalate", `{"value": "low"}`).Type)
+
+	tests := []struct {
+		tool  string
+		input string
+		err   string
+	}{
+		{"createTicket", `{"title": "a", "priority": "urgent"}`, `$.priority: must be one of "low", "high", got "urgent"`},
+		{"createTicket", `{"title": "a", "severity": 5}`, `$.severity: must be one of 1, 2, 3, got 5`},
+		{"createTicket", `{"title": "a", "regions": ["eu", "apac"]}`, `$.regions[1]: must be one of "eu", "us", got "apac"`},
+		{"createTicket", `{"title": "a", "parent": "urgent"}`, `$.parent: must be one of "low", "high", got "urgent"`},
+		// Zero values given explicitly are checked too
+		{"createTicket", `{"title": "a", "priority": ""}`, `$.priority: must be one of "low", "high", got ""`},
+		{"createTicket", `{"title": "a", "Severity": 0}`, `$.severity: must be one of 1, 2, 3, got 0`},
+		{"createTicket", `{"title": "a", "regions": ["eu", ""]}`, `$.regions[1]: must be one of "eu", "us", got ""`},
+		{"escalate", `{"value": ""}`, `$.value: must be one of "low", "high", got ""`},
+		{"escalate", `{"value": "urgent"}`, `$.value: must be one of "low", "high", got "urgent"`},
+	}
+	for _, test := range tests {
+		result := invoke(test.tool, test.input)
+		assert.Equal(t, ResultTypeRejection, result.Type, test.input)
+		assert.Equal(t, test.err, result.Value, test.input)
+	}
+}
+
+func TestDecodeInputEnums(t *testing.T) {
+	var input TicketInput
+	assert.EqualError(t, DecodeInput(json.RawMessage(`{"title": "a", "priority": "urgent"}`), &input), `$.priority: must be one of "low", "high", got "urgent"`)
+
+	// Registered enums are only known to agents
+	var other TicketInput
+	require.NoError(t, DecodeInput(json.RawMessage(`{"title": "a", "regions": ["apac"]}`), &other))
+}
@@ -0,0 +1,99 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GeneratedTool holds the code inferable-gen generates for a tool: its
+// schemas, computed ahead of time, and a function that decodes the input and
+// calls the tool directly. Tools registered with it are not called through
+// reflection, and are only reflected on at registration if types have been
+// mapped with MapType or enums registered with RegisterEnum, which the
+// precomputed schemas can't include.
+type GeneratedTool struct {
+	// JSON schema of the tool's input
+	Schema json.RawMessage
+	// JSON schema of the value the tool resolves with, if known
+	OutputSchema json.RawMessage
+	// Decodes the input and calls the tool
+	Call func(input json.RawMessage, ctx ContextInput) Result
+}
+
+func (g *GeneratedTool) validate(name string) error {
+	if g.Call == nil {
+		return fmt.Errorf("generated tool '%s' has no Call function", name)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(g.Schema, &schema); err != nil {
+		return fmt.Errorf("generated tool '%s' has an invalid schema: %v", name, err)
+	}
+
+	if len(g.OutputSchema) > 0 && !json.Valid(g.OutputSchema) {
+		return fmt.Errorf("generated tool '%s' has an invalid output schema", name)
+	}
+
+	return nil
+}
+
+// NewResult classifies the values returned by a tool the same way as a call
+// through reflection: a non-nil error makes it a rejection, with the error's
+// message or its ToolError as the value, a non-nil Interrupt an interrupt,
+// and otherwise it resolves with the first value.
+// It accepts a tool call directly, as in NewResult(tool(input, ctx)).
+func NewResult(values ...interface{}) Result {
+	result := Result{Type: ResultTypeResolution}
+	if len(values) > 0 {
+		result.Value = values[0]
+	}
+
+	for _, value := range values {
+		// Check if ANY of the return values is an error
+		if err, ok := value.(error); ok && !isNilError(err) {
+			result.Type = ResultTypeRejection
+			// Serialize the error, keeping the structure of a ToolError
+			result.Value = rejectionValue(err)
+			break
+		}
+
+		// Check if ANY of the return values is an interrupt
+		switch t := value.(type) {
+		case Interrupt:
+			result.Type = ResultTypeInterrupt
+			result.Value = t
+		case *Interrupt:
+			if t != nil {
+				result.Type = ResultTypeInterrupt
+				result.Value = *t
+			}
+		}
+	}
+
+	return result
+}
+
+// ReflectSchemas returns the input and output schemas Register reflects for a
+// tool function, as used by inferable-gen to precompute them. Call is left
+// unset.
+func ReflectSchemas(name string, fn interface{}) (GeneratedTool, error) {
+	schema, outputSchema, err := reflectTool(name, fn, schemaOptions{})
+	if err != nil {
+		return GeneratedTool{}, err
+	}
+
+	generated := GeneratedTool{}
+	generated.Schema, err = json.Marshal(schema)
+	if err != nil {
+		return GeneratedTool{}, fmt.Errorf("failed to marshal schema for tool '%s': %v", name, err)
+	}
+
+	if outputSchema != nil {
+		generated.OutputSchema, err = json.Marshal(outputSchema)
+		if err != nil {
+			return GeneratedTool{}, fmt.Errorf("failed to marshal output schema for tool '%s': %v", name, err)
+		}
+	}
+
+	return generated, nil
+}
@@ -0,0 +1,121 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/invopop/jsonschema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestNewResult(t *testing.T) {
+	assert.Equal(t, Result{Value: "ok", Type: ResultTypeResolution}, NewResult("ok", nil))
+	assert.Equal(t, Result{Value: "failed", Type: ResultTypeRejection}, NewResult(nil, fmt.Errorf("failed")))
+	assert.Equal(t, Result{Value: *ApprovalInterrupt(), Type: ResultTypeInterrupt}, NewResult(nil, ApprovalInterrupt(), nil))
+	assert.Equal(t, Result{Value: "ok", Type: ResultTypeResolution}, NewResult("ok", (*Interrupt)(nil), nil))
+	assert.Equal(t, Result{Type: ResultTypeResolution}, NewResult())
+}
+
+func TestRegisterGenerated(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	generated, err := ReflectSchemas("echo", echo)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","$schema":"https://json-schema.org/draft/2020-12/schema"}`, string(generated.OutputSchema))
+
+	generated.Call = func(input json.RawMessage, ctx ContextInput) Result {
+		var decoded EchoInput
+		if err := json.Unmarshal(input, &decoded); err != nil {
+			return NewResult(nil, err)
+		}
+		return NewResult(echo(decoded, ctx))
+	}
+
+	// Func isn't needed when the tool is generated
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Generated: &generated}))
+	require.NoError(t, i.Tools.register())
+
+	tool, ok := server.Tool("echo")
+	require.True(t, ok)
+	assert.JSONEq(t, string(generated.Schema), tool.Schema)
+	assert.JSONEq(t, string(generated.OutputSchema), tool.OutputSchema)
+
+	result, err := i.Tools.Invoke(context.Background(), "echo", json.RawMessage(`{"input":"hello"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Value)
+
+	result, err = i.Tools.Invoke(context.Background(), "echo", json.RawMessage(`{"input":1}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+
+	assert.Error(t, i.Tools.Register(Tool{Name: "noCall", Generated: &GeneratedTool{Schema: generated.Schema}}))
+	assert.Error(t, i.Tools.Register(Tool{Name: "badSchema", Generated: &GeneratedTool{Schema: json.RawMessage(`[]`), Call: generated.Call}}))
+
+	_, err = ReflectSchemas("invalid", func(input string) string { return input })
+	assert.Error(t, err)
+}
+
+func TestRegisterGeneratedWithEnums(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.RegisterEnum(reflect.TypeOf(Region("")), "eu", "us"))
+	i.Tools.MapType(reflect.TypeOf(time.Duration(0)), &jsonschema.Schema{Type: "string", Format: "duration"})
+
+	type DeployInput struct {
+		Regions []Region      `json:"regions"`
+		Timeout time.Duration `json:"timeout,omitempty"`
+	}
+	deploy := func(input DeployInput, ctx ContextInput) int { return len(input.Regions) }
+
+	// Schemas are generated without the client's enums and mapped types
+	generated, err := ReflectSchemas("deploy", deploy)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "array", "items": {"type": "string"}}`, propertyJSON(t, generated.Schema, "regions"))
+	generated.Call = func(input json.RawMessage, ctx ContextInput) Result {
+		var decoded DeployInput
+		if err := ctx.DecodeInput(input, &decoded); err != nil {
+			return NewResult(nil, err)
+		}
+		return NewResult(deploy(decoded, ctx))
+	}
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "deploy", Func: deploy, Generated: &generated}))
+
+	schema, err := json.Marshal(i.Tools.Tools()["deploy"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "array", "items": {"type": "string", "enum": ["eu", "us"]}}`, propertyJSON(t, schema, "regions"))
+	assert.JSONEq(t, `{"type": "string", "format": "duration"}`, propertyJSON(t, schema, "timeout"))
+
+	result, err := i.Tools.Invoke(context.Background(), "deploy", json.RawMessage(`{"regions": ["eu", "us"]}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Value)
+
+	result, err = i.Tools.Invoke(context.Background(), "deploy", json.RawMessage(`{"regions": ["apac"]}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+	assert.Equal(t, `$.regions[0]: must be one of "eu", "us", got "apac"`, result.Value)
+
+	// Without Func, the generated schema is used as is
+	require.NoError(t, i.Tools.Register(Tool{Name: "deployGenerated", Generated: &generated}))
+	assert.JSONEq(t, string(generated.Schema), string(i.Tools.Tools()["deployGenerated"].schema.(json.RawMessage)))
+}
@@ -0,0 +1,165 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/client"
+)
+
+const (
+	// How long the control plane waits for a result or a heartbeat before
+	// treating a job as stalled, unless the tool configures a timeout.
+	defaultJobTimeout = 30 * time.Second
+	// DefaultHeartbeatInterval is how often a heartbeat is sent for an
+	// executing job unless configured otherwise. It leaves room for a couple
+	// of heartbeats to fail or arrive late within the job timeout.
+	DefaultHeartbeatInterval = defaultJobTimeout / 3
+	// Least time between heartbeats, however often progress is reported.
+	minHeartbeatInterval = time.Second
+)
+
+// ReportProgress tells the control plane that the job is still executing,
+// and how far along it is, so that long running tools aren't mistaken for
+// stalled ones. The percent is between 0 and 100. Reports are sent at most
+// once a second, later ones replacing earlier ones that haven't been sent.
+//
+// It does nothing for calls that aren't jobs, such as those made with Invoke.
+func (c ContextInput) ReportProgress(message string, percent float64) {
+	if c.heartbeat != nil {
+		c.heartbeat.report(message, percent)
+	}
+}
+
+type heartbeatPayload struct {
+	Message  string   `json:"message,omitempty"`
+	Progress *float64 `json:"progress,omitempty"`
+}
+
+// jobHeartbeat sends heartbeats for an executing job: periodically, and
+// whenever progress is reported.
+type jobHeartbeat struct {
+	agent    *pollingAgent
+	jobID    string
+	interval time.Duration
+
+	mu     sync.Mutex
+	latest heartbeatPayload
+
+	reported chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startHeartbeat starts sending heartbeats for a job until stop is called.
+func (s *pollingAgent) startHeartbeat(jobID string) *jobHeartbeat {
+	h := &jobHeartbeat{
+		agent:    s,
+		jobID:    jobID,
+		interval: s.heartbeatInterval,
+		reported: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go h.run()
+	return h
+}
+
+func (h *jobHeartbeat) report(message string, percent float64) {
+	percent = math.Max(0, math.Min(100, percent))
+
+	h.mu.Lock()
+	h.latest = heartbeatPayload{Message: message, Progress: &percent}
+	h.mu.Unlock()
+
+	select {
+	case h.reported <- struct{}{}:
+	default:
+	}
+}
+
+func (h *jobHeartbeat) run() {
+	defer close(h.done)
+
+	var ticks <-chan time.Time
+	if h.interval > 0 {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var last time.Time
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticks:
+		case <-h.reported:
+			// Hold back reports that come too quickly
+			if wait := minHeartbeatInterval - time.Since(last); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-h.stop:
+					return
+				}
+			}
+		}
+
+		h.mu.Lock()
+		payload := h.latest
+		h.mu.Unlock()
+
+		last = time.Now()
+		h.agent.sendHeartbeat(h.jobID, payload)
+	}
+}
+
+// stopHeartbeat stops sending heartbeats, waiting for one in flight.
+func (h *jobHeartbeat) stopHeartbeat() {
+	close(h.stop)
+	<-h.done
+}
+
+// sendHeartbeat posts a heartbeat for a job. Heartbeats are best effort:
+// failures are logged, and if the control plane doesn't support them they
+// are disabled for the agent. Heartbeats for jobs that are no longer running
+// on the machine are rejected with a conflict rather than a not found.
+func (s *pollingAgent) sendHeartbeat(jobID string, payload heartbeatPayload) {
+	if s.heartbeatsUnsupported.Load() {
+		return
+	}
+
+	clusterId, err := s.inferable.getClusterId()
+	if err != nil {
+		log.Printf("Failed to send heartbeat for job %s: %v", jobID, err)
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to send heartbeat for job %s: %v", jobID, err)
+		return
+	}
+
+	_, _, err, status := s.inferable.fetchData(client.FetchDataOptions{
+		Path:    fmt.Sprintf("/clusters/%s/jobs/%s/heartbeat", clusterId, jobID),
+		Method:  "POST",
+		Headers: s.inferable.machineHeaders(),
+		Body:    string(body),
+	})
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		if !s.heartbeatsUnsupported.Swap(true) {
+			log.Printf("Control plane does not support heartbeats, disabling them for service '%s'", s.name)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to send heartbeat for job %s: %v", jobID, err)
+	}
+}
@@ -0,0 +1,120 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestHeartbeats(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	slow, err := i.Service("slow", ServiceOptions{HeartbeatInterval: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, slow.Register(Tool{
+		Name: "export",
+		Func: func(input EchoInput, ctx ContextInput) string {
+			ctx.ReportProgress("exporting", 150)
+			time.Sleep(250 * time.Millisecond)
+			return "done"
+		},
+	}))
+	require.NoError(t, slow.register())
+
+	jobID := server.Enqueue(t, "export", EchoInput{Input: "orders"})
+	require.NoError(t, slow.poll())
+
+	heartbeats := server.Heartbeats(jobID)
+	require.GreaterOrEqual(t, len(heartbeats), 3, "a progress report and periodic heartbeats")
+	for _, heartbeat := range heartbeats {
+		assert.Equal(t, "exporting", heartbeat.Message)
+		require.NotNil(t, heartbeat.Progress)
+		assert.Equal(t, 100.0, *heartbeat.Progress)
+	}
+
+	// No heartbeats are sent once the job has completed
+	count := len(heartbeats)
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, server.Heartbeats(jobID), count)
+
+	// Progress reports outside of jobs are ignored
+	_, err = slow.Invoke(context.Background(), "export", json.RawMessage(`{}`), ContextInput{})
+	require.NoError(t, err)
+}
+
+func TestDefaultHeartbeatInterval(t *testing.T) {
+	// Jobs must not time out between heartbeats, even if one is late
+	assert.Less(t, 2*DefaultHeartbeatInterval, defaultJobTimeout)
+}
+
+func TestHeartbeatsDisabledWhenUnsupported(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.register())
+
+	// Heartbeats for jobs that aren't running are rejected without
+	// disabling heartbeats
+	jobID := server.Enqueue(t, "echo", EchoInput{Input: "hello"})
+	i.Tools.sendHeartbeat(jobID, heartbeatPayload{Message: "working"})
+	i.Tools.sendHeartbeat("unknown-job", heartbeatPayload{})
+	assert.Empty(t, server.Heartbeats(jobID))
+	assert.False(t, i.Tools.heartbeatsUnsupported.Load())
+
+	// Control planes without the heartbeat route respond with a not found
+	var heartbeats atomic.Int32
+	unsupported := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		default:
+			heartbeats.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer unsupported.Close()
+
+	i, err = New(InferableOptions{
+		APIEndpoint:   unsupported.URL,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.register())
+
+	i.Tools.sendHeartbeat("job", heartbeatPayload{Message: "working"})
+	assert.True(t, i.Tools.heartbeatsUnsupported.Load())
+
+	i.Tools.sendHeartbeat("job", heartbeatPayload{Message: "working"})
+	assert.Equal(t, int32(1), heartbeats.Load())
+}
+
+func TestContextInputComparable(t *testing.T) {
+	// Context inputs of jobs and of local calls can be compared
+	assert.True(t, ContextInput{Approved: true} == ContextInput{Approved: true})
+	assert.False(t, ContextInput{heartbeat: &jobHeartbeat{}} == ContextInput{})
+}
@@ -0,0 +1,149 @@
+package inferable
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/lru"
+)
+
+// DefaultIdempotencyCapacity is the number of job results remembered by the
+// in-memory store agents use unless configured otherwise.
+const DefaultIdempotencyCapacity = 1000
+
+// IdempotencyStore records the results of completed jobs. A job that is
+// delivered again, for example after a re-registration or a failure to
+// persist its result, is answered from the store rather than running the
+// tool, and its side effects, a second time.
+//
+// Interrupts are not recorded, as the job is delivered again once the
+// interrupt is resolved, for example when a call is approved.
+type IdempotencyStore interface {
+	// Load returns the result recorded for a job, if any.
+	Load(jobID string) (Result, bool, error)
+	// Store records the result of a completed job.
+	Store(jobID string, result Result) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps the most recently
+// completed jobs in memory. Results are lost when the process exits.
+type MemoryIdempotencyStore struct {
+	results *lru.Cache[Result]
+}
+
+// NewMemoryIdempotencyStore creates a store remembering up to capacity jobs,
+// evicting the least recently used. A capacity of 0 uses
+// DefaultIdempotencyCapacity.
+func NewMemoryIdempotencyStore(capacity int) *MemoryIdempotencyStore {
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyCapacity
+	}
+
+	return &MemoryIdempotencyStore{
+		results: lru.New[Result](capacity),
+	}
+}
+
+func (m *MemoryIdempotencyStore) Load(jobID string) (Result, bool, error) {
+	result, ok := m.results.Get(jobID)
+	return result, ok, nil
+}
+
+func (m *MemoryIdempotencyStore) Store(jobID string, result Result) error {
+	m.results.Add(jobID, result)
+	return nil
+}
+
+// FileIdempotencyStore is an IdempotencyStore that keeps one file per job in
+// a directory, so that results survive restarts. Files are never removed by
+// the store; expire them externally if needed.
+type FileIdempotencyStore struct {
+	dir string
+}
+
+type fileEntry struct {
+	Value         json.RawMessage `json:"value"`
+	Type          ResultType      `json:"type"`
+	ExecutionTime time.Duration   `json:"executionTime"`
+}
+
+// NewFileIdempotencyStore creates a store in dir, creating it if needed.
+func NewFileIdempotencyStore(dir string) (*FileIdempotencyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create idempotency store directory: %v", err)
+	}
+
+	return &FileIdempotencyStore{dir: dir}, nil
+}
+
+// Load returns the recorded result with its value as json.RawMessage.
+func (f *FileIdempotencyStore) Load(jobID string) (Result, bool, error) {
+	data, err := os.ReadFile(f.path(jobID))
+	if os.IsNotExist(err) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, fmt.Errorf("failed to read recorded result: %v", err)
+	}
+
+	entry := fileEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Result{}, false, fmt.Errorf("failed to parse recorded result: %v", err)
+	}
+
+	return Result{
+		Value:         entry.Value,
+		Type:          entry.Type,
+		ExecutionTime: entry.ExecutionTime,
+	}, true, nil
+}
+
+func (f *FileIdempotencyStore) Store(jobID string, result Result) error {
+	value, err := json.Marshal(result.Value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %v", err)
+	}
+
+	data, err := json.Marshal(fileEntry{
+		Value:         value,
+		Type:          result.Type,
+		ExecutionTime: result.ExecutionTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %v", err)
+	}
+
+	// Write to a temporary file first so that a crash can't leave a partial
+	// result behind
+	tmp, err := os.CreateTemp(f.dir, ".job-*")
+	if err != nil {
+		return fmt.Errorf("failed to record result: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to record result: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to record result: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path(jobID)); err != nil {
+		return fmt.Errorf("failed to record result: %v", err)
+	}
+
+	return nil
+}
+
+// path returns the file for a job. Job IDs are hashed, as they come from the
+// control plane and can't be trusted as file names.
+func (f *FileIdempotencyStore) path(jobID string) string {
+	sum := sha256.Sum256([]byte(jobID))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,131 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := NewMemoryIdempotencyStore(2)
+
+	_, ok, err := store.Load("a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.Store("a", Result{Value: 1, Type: ResultTypeResolution}))
+	require.NoError(t, store.Store("b", Result{Value: 2, Type: ResultTypeResolution}))
+
+	// Loading a marks it as recently used, so b is evicted
+	_, ok, _ = store.Load("a")
+	assert.True(t, ok)
+	require.NoError(t, store.Store("c", Result{Value: 3, Type: ResultTypeResolution}))
+
+	_, ok, _ = store.Load("b")
+	assert.False(t, ok)
+	result, ok, _ := store.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, result.Value)
+	_, ok, _ = store.Load("c")
+	assert.True(t, ok)
+}
+
+func TestFileIdempotencyStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileIdempotencyStore(dir)
+	require.NoError(t, err)
+
+	_, ok, err := store.Load("job/../1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.Store("job/../1", Result{
+		Value:         map[string]string{"status": "charged"},
+		Type:          ResultTypeResolution,
+		ExecutionTime: time.Second,
+	}))
+
+	// Results survive a new store over the same directory
+	reopened, err := NewFileIdempotencyStore(dir)
+	require.NoError(t, err)
+
+	result, ok, err := reopened.Load("job/../1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, ResultTypeResolution, result.Type)
+	assert.Equal(t, time.Second, result.ExecutionTime)
+	assert.JSONEq(t, `{"status":"charged"}`, string(result.Value.(json.RawMessage)))
+}
+
+func TestRedeliveredJobsAreNotExecutedAgain(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	store, err := NewFileIdempotencyStore(t.TempDir())
+	require.NoError(t, err)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:      server.URL,
+		APISecret:        server.Secret,
+		IdempotencyStore: store,
+		MachineIDPath:    filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	var charges atomic.Int64
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "charge",
+		Func: func(input EchoInput, ctx ContextInput) (string, *Interrupt, error) {
+			if input.Input == "approve" && !ctx.Approved {
+				return "", ApprovalInterrupt(), nil
+			}
+			if input.Input == "fail" {
+				return "", nil, fmt.Errorf("card declined")
+			}
+			return fmt.Sprintf("charge %d", charges.Add(1)), nil, nil
+		},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	jobID := server.Enqueue(t, "charge", EchoInput{Input: "card"})
+	require.NoError(t, i.Tools.poll())
+
+	// A redelivered job is answered with the recorded result
+	msg := callMessage{Id: jobID, Function: "charge", Input: map[string]string{"Input": "card"}}
+	require.NoError(t, i.Tools.handleMessage(msg))
+	assert.Equal(t, int64(1), charges.Load())
+
+	result, ok := server.Result(jobID)
+	require.True(t, ok)
+	assert.JSONEq(t, `"charge 1"`, string(result.Result))
+
+	// Rejections are recorded too
+	failedID := server.Enqueue(t, "charge", EchoInput{Input: "fail"})
+	require.NoError(t, i.Tools.poll())
+	require.NoError(t, i.Tools.handleMessage(callMessage{Id: failedID, Function: "charge", Input: map[string]string{"Input": "card"}}))
+	result, _ = server.Result(failedID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Equal(t, int64(1), charges.Load())
+
+	// Interrupts aren't, so the job runs again once approved
+	approvalID := server.Enqueue(t, "charge", EchoInput{Input: "approve"})
+	require.NoError(t, i.Tools.poll())
+	result, _ = server.Result(approvalID)
+	assert.Equal(t, "interrupt", result.ResultType)
+
+	require.NoError(t, i.Tools.handleMessage(callMessage{Id: approvalID, Function: "charge", Input: map[string]string{"Input": "approve"}, Approved: true}))
+	result, _ = server.Result(approvalID)
+	assert.Equal(t, "resolution", result.ResultType)
+	assert.Equal(t, int64(2), charges.Load())
+
+	metrics := i.Tools.Metrics()
+	assert.Equal(t, int64(3), metrics.Resolutions)
+	assert.Equal(t, int64(2), metrics.Rejections)
+}
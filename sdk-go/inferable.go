@@ -4,8 +4,10 @@ package inferable
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"reflect"
+	"sync"
 
 	"github.com/inferablehq/inferable/sdk-go/internal/client"
 	"github.com/inferablehq/inferable/sdk-go/internal/util"
@@ -16,15 +18,37 @@ const Version = "0.1.42"
 
 const (
 	DefaultAPIEndpoint = "https://api.inferable.ai"
+	// DefaultPollWaitTime is the number of seconds the control plane holds a
+	// job poll open while waiting for new jobs.
+	DefaultPollWaitTime = 20
+	// MaxPollWaitTime is the longest wait time accepted by the control plane.
+	MaxPollWaitTime = 20
 )
 
 type Inferable struct {
 	client      *client.Client
 	apiEndpoint string
-	apiSecret   string
+	secrets     SecretProvider
 	machineID   string
-	clusterID   string
-	Tools       *pollingAgent
+	// Lazily set on first use, guarded by clusterMu
+	clusterID string
+	clusterMu sync.Mutex
+	// Seconds to long poll for jobs, or 0 to disable long polling.
+	pollWaitTime int
+	// All agents by service name, including Tools. Guarded by servicesMu.
+	services   map[string]*pollingAgent
+	servicesMu sync.Mutex
+	// Clusters attached with AttachCluster. Guarded by clustersMu.
+	clusters   []*Cluster
+	clustersMu sync.Mutex
+	// Store used by services that don't configure their own, may be nil
+	idempotencyStore IdempotencyStore
+	// Size limit for results of services that don't configure their own
+	resultLimit ResultLimit
+	// Doc comments added with AddGoComments. Guarded by commentsMu.
+	comments   map[string]string
+	commentsMu sync.Mutex
+	Tools      *pollingAgent
 	// Convenience reference to a service with the name 'default'.
 	//
 	// Returns:
@@ -53,10 +77,32 @@ type Inferable struct {
 	//  defer client.Default.Stop()
 }
 
+// InferableOptions configures an Inferable instance. Options that are left
+// empty are read from the environment (see EnvAPISecret and friends) and then
+// from ConfigFile.
 type InferableOptions struct {
 	APIEndpoint string
 	APISecret   string
-	MachineID   string
+	// SecretProvider supplies the API secret for each request, allowing it to
+	// be rotated while the agent is running. Takes precedence over APISecret.
+	SecretProvider SecretProvider
+	MachineID      string
+	// ConfigFile is an optional YAML or JSON file to read options from.
+	// Defaults to the value of INFERABLE_CONFIG_FILE.
+	ConfigFile string
+	// MachineIDPath is where a generated machine ID is persisted between
+	// restarts. Defaults to a file in the user's configuration directory.
+	MachineIDPath string
+	// PollWaitTime is the number of seconds the control plane may hold a job
+	// poll open before returning an empty result. Zero uses DefaultPollWaitTime,
+	// a negative value disables long polling.
+	PollWaitTime int
+	// IdempotencyStore records completed jobs so that redelivered jobs aren't
+	// executed twice. Defaults to an in-memory store per service.
+	IdempotencyStore IdempotencyStore
+	// ResultLimit limits the size of tool results. Defaults to rejecting
+	// results over DefaultMaxResultBytes.
+	ResultLimit *ResultLimit
 }
 
 // Input object for onStatusChange functions
@@ -75,49 +121,164 @@ type HandleCustomAuthInput struct {
 }
 
 func New(options InferableOptions) (*Inferable, error) {
+	options, err := resolveOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %v", err)
+	}
+
 	if options.APIEndpoint == "" {
 		options.APIEndpoint = DefaultAPIEndpoint
 	}
-	client, err := client.NewClient(client.ClientOptions{
-		Endpoint: options.APIEndpoint,
-		Secret:   options.APISecret,
+
+	pollWaitTime := options.PollWaitTime
+	if pollWaitTime == 0 {
+		pollWaitTime = DefaultPollWaitTime
+	}
+	if pollWaitTime < 0 {
+		pollWaitTime = 0
+	}
+	if pollWaitTime > MaxPollWaitTime {
+		return nil, fmt.Errorf("poll wait time must not exceed %d seconds, got %d", MaxPollWaitTime, pollWaitTime)
+	}
+
+	resultLimit, err := resolveResultLimit(options.ResultLimit, ResultLimit{
+		MaxBytes: DefaultMaxResultBytes,
+		Policy:   ResultLimitReject,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error creating client: %v", err)
+		return nil, fmt.Errorf("invalid result limit: %v", err)
 	}
 
-	machineID := options.MachineID
-	if machineID == "" {
-		machineID = util.GenerateMachineID(8)
+	secrets := options.SecretProvider
+	if secrets == nil {
+		secrets = StaticSecret(options.APISecret)
 	}
 
 	inferable := &Inferable{
-		client:      client,
-		apiEndpoint: options.APIEndpoint,
-		apiSecret:   options.APISecret,
-		machineID:   machineID,
+		apiEndpoint:      options.APIEndpoint,
+		secrets:          secrets,
+		pollWaitTime:     pollWaitTime,
+		idempotencyStore: options.IdempotencyStore,
+		resultLimit:      resultLimit,
+	}
+
+	// Fail fast if the provider can't supply a usable secret
+	secret, err := inferable.currentSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAPISecret(secret); err != nil {
+		return nil, err
+	}
+
+	inferable.client, err = client.NewClient(client.ClientOptions{
+		Endpoint: options.APIEndpoint,
+		Secret:   inferable.currentSecret,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+
+	inferable.machineID = options.MachineID
+	if inferable.machineID == "" {
+		inferable.machineID = loadMachineID(options.MachineIDPath)
 	}
 
 	// Automatically register the default service
-	inferable.Tools, err = inferable.createPollingAgent()
+	inferable.services = make(map[string]*pollingAgent)
+	inferable.Tools, err = inferable.createPollingAgent(DefaultServiceName, ServiceOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error creating polling agent: %v", err)
 	}
+	inferable.services[DefaultServiceName] = inferable.Tools
 
 	return inferable, nil
 }
 
-func (i *Inferable) createPollingAgent() (*pollingAgent, error) {
+// loadMachineID returns the persisted machine ID, falling back to an ID
+// derived from the host if it can't be stored.
+func loadMachineID(path string) string {
+	if path == "" {
+		defaultPath, err := util.DefaultMachineIDPath()
+		if err != nil {
+			log.Printf("Failed to persist machine ID, using derived ID: %v", err)
+			return util.GenerateMachineID(8)
+		}
+		path = defaultPath
+	}
+
+	machineID, err := util.LoadOrCreateMachineID(path, 16)
+	if err != nil {
+		log.Printf("Failed to persist machine ID, using derived ID: %v", err)
+		return util.GenerateMachineID(8)
+	}
+
+	return machineID
+}
+
+func (i *Inferable) createPollingAgent(name string, options ServiceOptions) (*pollingAgent, error) {
+	maxConcurrency := options.MaxConcurrency
+	if maxConcurrency == 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	if maxConcurrency < 0 || maxConcurrency > MaxConcurrency {
+		return nil, fmt.Errorf("max concurrency must be between 1 and %d, got %d", MaxConcurrency, maxConcurrency)
+	}
+
+	pollWaitTime := i.pollWaitTime
+	if options.PollWaitTime < 0 {
+		pollWaitTime = 0
+	}
+	if options.PollWaitTime > 0 {
+		pollWaitTime = options.PollWaitTime
+	}
+	if pollWaitTime > MaxPollWaitTime {
+		return nil, fmt.Errorf("poll wait time must not exceed %d seconds, got %d", MaxPollWaitTime, pollWaitTime)
+	}
 
+	heartbeatInterval := options.HeartbeatInterval
+	if heartbeatInterval == 0 {
+		heartbeatInterval = DefaultHeartbeatInterval
+	}
+	if heartbeatInterval < 0 {
+		heartbeatInterval = 0
+	}
+
+	idempotencyStore := options.IdempotencyStore
+	if idempotencyStore == nil {
+		idempotencyStore = i.defaultIdempotencyStore()
+	}
+
+	resultLimit, err := resolveResultLimit(options.ResultLimit, i.resultLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result limit: %v", err)
+	}
+
+	registry := newToolRegistry()
 	agent := &pollingAgent{
-		Tools:     make(map[string]Tool),
-		inferable: i, // Set the reference to the Inferable instance
+		registry:          registry,
+		inferable:         i, // Set the reference to the Inferable instance
+		name:              name,
+		maxConcurrency:    maxConcurrency,
+		pollWaitTime:      pollWaitTime,
+		idempotencyStore:  idempotencyStore,
+		heartbeatInterval: heartbeatInterval,
+		resultLimit:       resultLimit,
 	}
+	registry.attach(agent)
 	return agent, nil
 }
 
+// defaultIdempotencyStore returns the store for an agent without its own.
+func (i *Inferable) defaultIdempotencyStore() IdempotencyStore {
+	if i.idempotencyStore != nil {
+		return i.idempotencyStore
+	}
+	return NewMemoryIdempotencyStore(DefaultIdempotencyCapacity)
+}
+
 func (i *Inferable) callFunc(funcName string, args ...interface{}) ([]reflect.Value, error) {
-	fn, exists := i.Tools.Tools[funcName]
+	fn, exists := i.Tools.getTool(funcName)
 	if !exists {
 		return nil, fmt.Errorf("function with name '%s' not found", funcName)
 	}
@@ -153,6 +314,16 @@ func (i *Inferable) fetchData(options client.FetchDataOptions) ([]byte, http.Hea
 	return []byte(data), headers, err, status
 }
 
+// machineHeaders identifies this machine to the control plane. The
+// Authorization header is added by the client.
+func (i *Inferable) machineHeaders() map[string]string {
+	return map[string]string{
+		"X-Machine-ID":           i.machineID,
+		"X-Machine-SDK-Version":  Version,
+		"X-Machine-SDK-Language": "go",
+	}
+}
+
 func (i *Inferable) serverOk() error {
 	data, _, err, _ := i.client.FetchData(client.FetchDataOptions{
 		Path:   "/live",
@@ -179,6 +350,9 @@ func (i *Inferable) serverOk() error {
 }
 
 func (i *Inferable) getClusterId() (string, error) {
+	i.clusterMu.Lock()
+	defer i.clusterMu.Unlock()
+
 	if i.clusterID == "" {
 		clusterId, err := i.registerMachine(nil)
 		if err != nil {
@@ -191,39 +365,63 @@ func (i *Inferable) getClusterId() (string, error) {
 	return i.clusterID, nil
 }
 
+func (i *Inferable) setClusterId(clusterId string) {
+	i.clusterMu.Lock()
+	defer i.clusterMu.Unlock()
+
+	i.clusterID = clusterId
+}
+
 func (i *Inferable) registerMachine(s *pollingAgent) (string, error) {
 
 	// Prepare the payload for registration
 	payload := struct {
 		Service string `json:"service,omitempty"`
 		Tools   []struct {
-			Name        string `json:"name"`
-			Description string `json:"description,omitempty"`
-			Schema      string `json:"schema,omitempty"`
+			Name         string `json:"name"`
+			Description  string `json:"description,omitempty"`
+			Schema       string `json:"schema,omitempty"`
+			OutputSchema string `json:"outputSchema,omitempty"`
 		} `json:"tools,omitempty"`
 	}{}
 
 	if s != nil {
+		if s.name != DefaultServiceName {
+			payload.Service = s.name
+		}
+
+		tools := s.toolList()
+
 		// Check if there are any registered functions
-		if len(s.Tools) == 0 {
+		if len(tools) == 0 {
 			return "", fmt.Errorf("cannot register machine with no functions")
 		}
 
 		// Add registered functions to the payload
-		for _, fn := range s.Tools {
+		for _, fn := range tools {
 			schemaJSON, err := json.Marshal(fn.schema)
 			if err != nil {
 				return "", fmt.Errorf("failed to marshal schema for function '%s': %v", fn.Name, err)
 			}
 
+			outputSchemaJSON := []byte{}
+			if fn.outputSchema != nil {
+				outputSchemaJSON, err = json.Marshal(fn.outputSchema)
+				if err != nil {
+					return "", fmt.Errorf("failed to marshal output schema for function '%s': %v", fn.Name, err)
+				}
+			}
+
 			payload.Tools = append(payload.Tools, struct {
-				Name        string `json:"name"`
-				Description string `json:"description,omitempty"`
-				Schema      string `json:"schema,omitempty"`
+				Name         string `json:"name"`
+				Description  string `json:"description,omitempty"`
+				Schema       string `json:"schema,omitempty"`
+				OutputSchema string `json:"outputSchema,omitempty"`
 			}{
-				Name:        fn.Name,
-				Description: fn.Description,
-				Schema:      string(schemaJSON),
+				Name:         fn.Name,
+				Description:  fn.Description,
+				Schema:       string(schemaJSON),
+				OutputSchema: string(outputSchemaJSON),
 			})
 		}
 	}
@@ -234,19 +432,11 @@ func (i *Inferable) registerMachine(s *pollingAgent) (string, error) {
 		return "", fmt.Errorf("failed to marshal payload: %v", err)
 	}
 
-	// Prepare headers
-	headers := map[string]string{
-		"Authorization":          "Bearer " + i.apiSecret,
-		"X-Machine-ID":           i.machineID,
-		"X-Machine-SDK-Version":  Version,
-		"X-Machine-SDK-Language": "go",
-	}
-
 	// Call the registerMachine endpoint
 	options := client.FetchDataOptions{
 		Path:    "/machines",
 		Method:  "POST",
-		Headers: headers,
+		Headers: i.machineHeaders(),
 		Body:    string(jsonPayload),
 	}
 
@@ -3,27 +3,34 @@ package inferable
 import (
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/util"
 )
 
 func TestNew(t *testing.T) {
 	i, err := New(InferableOptions{
-		APIEndpoint: DefaultAPIEndpoint,
-		APISecret:   "test-secret",
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 	require.NoError(t, err)
 	assert.Equal(t, DefaultAPIEndpoint, i.apiEndpoint)
-	assert.Equal(t, "test-secret", i.apiSecret)
+	secret, err := i.currentSecret()
+	require.NoError(t, err)
+	assert.Equal(t, "test-secret", secret)
 	assert.NotEmpty(t, i.machineID)
 }
 
 func TestCallFunc(t *testing.T) {
 	i, _ := New(InferableOptions{
-		APIEndpoint: DefaultAPIEndpoint,
-		APISecret:   "test-secret",
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 
 	type TestInput struct {
@@ -59,14 +66,21 @@ func TestServerOk(t *testing.T) {
 	defer server.Close()
 
 	i, _ := New(InferableOptions{
-		APIEndpoint: server.URL,
-		APISecret:   "test-secret",
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 	err := i.serverOk()
 	assert.NoError(t, err)
 }
 
 func TestGetMachineID(t *testing.T) {
+	// Keep the default machine ID path out of the user's configuration
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+
 	i, _ := New(InferableOptions{
 		APIEndpoint: DefaultAPIEndpoint,
 		APISecret:   "test-secret",
@@ -80,4 +94,29 @@ func TestGetMachineID(t *testing.T) {
 		APISecret:   "test-secret",
 	})
 	assert.Equal(t, machineID, i2.machineID)
+
+	path, err := util.DefaultMachineIDPath()
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+}
+
+func TestMachineIDPersistedToPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.json")
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: path,
+	})
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	i2, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: path,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, i.machineID, i2.machineID)
+	assert.NotEqual(t, util.GenerateMachineID(8), i.machineID)
 }
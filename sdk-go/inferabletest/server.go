@@ -0,0 +1,663 @@
+// Package inferabletest provides an in-process stand-in for the Inferable
+// control plane, so that tools can be tested without network access.
+//
+// Example:
+//
+//	server := inferabletest.NewServer(t)
+//
+//	client, _ := inferable.New(inferable.InferableOptions{
+//	    APIEndpoint: server.URL,
+//	    APISecret:   server.Secret,
+//	})
+//
+//	client.Tools.Register(inferable.Tool{Name: "echo", Func: echo})
+//	client.Tools.Listen()
+//	defer client.Tools.Unlisten()
+//
+//	jobID := server.Enqueue(t, "echo", map[string]string{"input": "hello"})
+//	result := server.WaitForResult(t, jobID)
+package inferabletest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	// DefaultSecret is the API secret accepted by a new Server.
+	DefaultSecret = "sk_inferabletest"
+	// DefaultClusterID is the cluster ID returned on machine registration.
+	DefaultClusterID = "inferabletest-cluster"
+	// DefaultResultTimeout is how long WaitForResult waits for a job result.
+	DefaultResultTimeout = 5 * time.Second
+	// MaxWaitTime is the longest a job poll is held open, in seconds.
+	MaxWaitTime = 20
+	// The largest request body accepted, the control plane's limit.
+	maxBodyBytes = 1 << 20
+)
+
+// Job statuses, matching the control plane.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusSuccess = "success"
+)
+
+// Server is a fake control plane serving the endpoints used by the SDK.
+type Server struct {
+	// URL to use as the API endpoint.
+	URL string
+	// Secret machines must authenticate with. Use SetSecret to change it
+	// while the server is running.
+	Secret string
+	// ClusterID returned to registering machines.
+	ClusterID string
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	order    []string
+	tools    map[string]RegisteredTool
+	machines map[string]int
+	// Closed and replaced whenever jobs change, to wake long polls.
+	changed chan struct{}
+	closed  chan struct{}
+}
+
+// Number of jobs enqueued by all servers. Job IDs are unique across servers,
+// as the control plane's are.
+var jobCount atomic.Int64
+
+// RegisteredTool is a tool as registered through /machines.
+type RegisteredTool struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	Schema       string          `json:"schema,omitempty"`
+	OutputSchema string          `json:"outputSchema,omitempty"`
+	Config       json.RawMessage `json:"config,omitempty"`
+}
+
+// JobInput describes a job to enqueue.
+type JobInput struct {
+	Tool        string
+	Input       interface{}
+	AuthContext interface{}
+	RunContext  interface{}
+	Approved    bool
+}
+
+// Job is a job known to the server, along with its result once persisted.
+type Job struct {
+	ID          string
+	Tool        string
+	Input       json.RawMessage
+	AuthContext interface{}
+	RunContext  interface{}
+	Approved    bool
+	Status      string
+	// The machine that acknowledged the job.
+	MachineID string
+	Result    *JobResult
+	// Heartbeats sent while the job was executing.
+	Heartbeats []Heartbeat
+	// Blobs uploaded for the job's result.
+	Blobs []Blob
+}
+
+// Blob is a file uploaded by a machine for a job's result.
+type Blob struct {
+	ID   string
+	Name string
+	Type string
+	Data []byte
+}
+
+// Heartbeat is a progress report sent by a machine for an executing job.
+type Heartbeat struct {
+	Message  string   `json:"message,omitempty"`
+	Progress *float64 `json:"progress,omitempty"`
+}
+
+// JobResult is the payload persisted by a machine for a job.
+type JobResult struct {
+	Result     json.RawMessage        `json:"result"`
+	ResultType string                 `json:"resultType"`
+	Meta       map[string]interface{} `json:"meta"`
+}
+
+// Decode unmarshals the result value into v.
+func (r JobResult) Decode(v interface{}) error {
+	return json.Unmarshal(r.Result, v)
+}
+
+// NewServer starts a Server which is closed when the test completes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		Secret:    DefaultSecret,
+		ClusterID: DefaultClusterID,
+		jobs:      make(map[string]*Job),
+		tools:     make(map[string]RegisteredTool),
+		machines:  make(map[string]int),
+		changed:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /live", s.handleLive)
+	mux.HandleFunc("POST /machines", s.authenticated(s.handleCreateMachine))
+	mux.HandleFunc("GET /clusters/{clusterId}/jobs", s.authenticated(s.handleListJobs))
+	mux.HandleFunc("POST /clusters/{clusterId}/jobs", s.authenticated(s.handleCreateJob))
+	mux.HandleFunc("POST /clusters/{clusterId}/jobs/{jobId}/result", s.authenticated(s.handleCreateJobResult))
+	mux.HandleFunc("POST /clusters/{clusterId}/jobs/{jobId}/heartbeat", s.authenticated(s.handleCreateJobHeartbeat))
+	mux.HandleFunc("POST /clusters/{clusterId}/jobs/{jobId}/blobs", s.authenticated(s.handleCreateJobBlob))
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Close stops the server, releasing any long polls in progress.
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return
+	default:
+		close(s.closed)
+	}
+	s.mu.Unlock()
+
+	s.server.Close()
+}
+
+// SetSecret changes the secret machines must authenticate with, simulating
+// a key rotation.
+func (s *Server) SetSecret(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Secret = secret
+}
+
+// Enqueue creates a pending job for the named tool and returns its ID,
+// failing the test if the input can't be marshalled.
+func (s *Server) Enqueue(t testing.TB, tool string, input interface{}) string {
+	t.Helper()
+
+	id, err := s.EnqueueJob(JobInput{Tool: tool, Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// EnqueueJob creates a pending job and returns its ID.
+func (s *Server) EnqueueJob(input JobInput) (string, error) {
+	data, err := json.Marshal(input.Input)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job input: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := fmt.Sprintf("job-%d", jobCount.Add(1))
+
+	s.jobs[id] = &Job{
+		ID:          id,
+		Tool:        input.Tool,
+		Input:       data,
+		AuthContext: input.AuthContext,
+		RunContext:  input.RunContext,
+		Approved:    input.Approved,
+		Status:      StatusPending,
+	}
+	s.order = append(s.order, id)
+	s.notifyLocked()
+
+	return id, nil
+}
+
+// Job returns a copy of the job with the given ID.
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	copied := *job
+	copied.Heartbeats = append([]Heartbeat(nil), job.Heartbeats...)
+	copied.Blobs = append([]Blob(nil), job.Blobs...)
+	return copied, true
+}
+
+// Heartbeats returns the heartbeats sent for a job.
+func (s *Server) Heartbeats(id string) []Heartbeat {
+	job, _ := s.Job(id)
+	return job.Heartbeats
+}
+
+// Blobs returns the blobs uploaded for a job.
+func (s *Server) Blobs(id string) []Blob {
+	job, _ := s.Job(id)
+	return job.Blobs
+}
+
+// Result returns the persisted result for a job, if there is one.
+func (s *Server) Result(id string) (JobResult, bool) {
+	job, ok := s.Job(id)
+	if !ok || job.Result == nil {
+		return JobResult{}, false
+	}
+	return *job.Result, true
+}
+
+// WaitForResult waits up to DefaultResultTimeout for a job's result to be
+// persisted, failing the test if it isn't.
+func (s *Server) WaitForResult(t testing.TB, id string) JobResult {
+	t.Helper()
+
+	result, err := s.AwaitResult(id, DefaultResultTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// AwaitResult waits up to timeout for a job's result to be persisted.
+func (s *Server) AwaitResult(id string, timeout time.Duration) (JobResult, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		job, ok := s.jobs[id]
+		if !ok {
+			s.mu.Unlock()
+			return JobResult{}, fmt.Errorf("job %s does not exist", id)
+		}
+		if job.Result != nil {
+			result := *job.Result
+			s.mu.Unlock()
+			return result, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return JobResult{}, fmt.Errorf("timed out waiting for result of job %s", id)
+		case <-s.closed:
+			return JobResult{}, fmt.Errorf("server closed while waiting for result of job %s", id)
+		}
+	}
+}
+
+// Tools returns the registered tools, sorted by name.
+func (s *Server) Tools() []RegisteredTool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tools := make([]RegisteredTool, 0, len(s.tools))
+	for _, tool := range s.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	return tools
+}
+
+// Tool returns a registered tool by name.
+func (s *Server) Tool(name string) (RegisteredTool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tool, ok := s.tools[name]
+	return tool, ok
+}
+
+// Registrations returns how many times the given machine has registered.
+func (s *Server) Registrations(machineID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.machines[machineID]
+}
+
+// notifyLocked wakes anyone waiting on a change. s.mu must be held.
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		secret := s.Secret
+		s.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer "+secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if clusterID := r.PathValue("clusterId"); clusterID != "" && clusterID != s.ClusterID {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "cluster not found"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleCreateMachine(w http.ResponseWriter, r *http.Request) {
+	machineID := r.Header.Get("X-Machine-ID")
+	if machineID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Request does not contain machine ID header"})
+		return
+	}
+
+	var body struct {
+		Service string           `json:"service"`
+		Tools   []RegisteredTool `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	for _, tool := range body.Tools {
+		s.tools[tool.Name] = tool
+	}
+	s.machines[machineID]++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"clusterId": s.ClusterID})
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 10
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	waitTime := 0
+	if wt, err := strconv.Atoi(query.Get("waitTime")); err == nil && wt > 0 {
+		waitTime = min(wt, MaxWaitTime)
+	}
+
+	tools := map[string]bool{}
+	for _, tool := range strings.Split(query.Get("tools"), ",") {
+		if tool != "" {
+			tools[tool] = true
+		}
+	}
+
+	acknowledge := query.Get("acknowledge") == "true"
+	machineID := r.Header.Get("X-Machine-ID")
+
+	deadline := time.NewTimer(time.Duration(waitTime) * time.Second)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		jobs := []map[string]interface{}{}
+		for _, id := range s.order {
+			job := s.jobs[id]
+			if job.Status != StatusPending || (len(tools) > 0 && !tools[job.Tool]) {
+				continue
+			}
+			if len(jobs) >= limit {
+				break
+			}
+
+			if acknowledge {
+				job.Status = StatusRunning
+				job.MachineID = machineID
+			}
+
+			jobs = append(jobs, map[string]interface{}{
+				"id":          job.ID,
+				"function":    job.Tool,
+				"input":       job.Input,
+				"authContext": job.AuthContext,
+				"runContext":  job.RunContext,
+				"approved":    job.Approved,
+			})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(jobs) > 0 || waitTime == 0 {
+			writeJSON(w, http.StatusOK, jobs)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			writeJSON(w, http.StatusOK, jobs)
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			writeJSON(w, http.StatusOK, jobs)
+			return
+		}
+	}
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Function string                 `json:"function"`
+		Tool     string                 `json:"tool"`
+		Input    map[string]interface{} `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	tool := body.Tool
+	if tool == "" {
+		tool = body.Function
+	}
+	if tool == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "No function or tool provided"})
+		return
+	}
+
+	id, err := s.EnqueueJob(JobInput{Tool: tool, Input: body.Input})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	response := map[string]interface{}{
+		"id":         id,
+		"status":     StatusPending,
+		"result":     nil,
+		"resultType": nil,
+	}
+
+	if wt, err := strconv.Atoi(r.URL.Query().Get("waitTime")); err == nil && wt > 0 {
+		result, err := s.AwaitResult(id, time.Duration(min(wt, MaxWaitTime))*time.Second)
+		if err == nil {
+			response["status"] = StatusSuccess
+			response["result"] = result.Result
+			response["resultType"] = result.ResultType
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleCreateJobResult(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Machine-ID") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Request does not contain machine ID header"})
+		return
+	}
+
+	var result JobResult
+	if !decodeBody(w, r, &result) {
+		return
+	}
+
+	switch result.ResultType {
+	case "resolution", "rejection", "interrupt":
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("invalid resultType %q", result.ResultType)})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[r.PathValue("jobId")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "job not found"})
+		return
+	}
+
+	job.Status = StatusSuccess
+	job.Result = &result
+	s.notifyLocked()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCreateJobHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Machine-ID") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Request does not contain machine ID header"})
+		return
+	}
+
+	var heartbeat Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	if heartbeat.Progress != nil && (*heartbeat.Progress < 0 || *heartbeat.Progress > 100) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "progress must be between 0 and 100"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like the control plane, a conflict rather than a not found, which
+	// machines take to mean that heartbeats aren't supported
+	job, ok := s.jobs[r.PathValue("jobId")]
+	if !ok || job.Status != StatusRunning || job.MachineID != r.Header.Get("X-Machine-ID") {
+		writeJSON(w, http.StatusConflict, map[string]string{"message": "Job is not running on this machine"})
+		return
+	}
+
+	job.Heartbeats = append(job.Heartbeats, heartbeat)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCreateJobBlob(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Encoding string `json:"encoding"`
+		Size     int    `json:"size"`
+		Data     string `json:"data"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	if body.Encoding != "base64" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "unsupported encoding"})
+		return
+	}
+
+	// The types the control plane accepts
+	switch body.Type {
+	case "application/json", "image/png", "image/jpeg", "application/pdf", "text/csv":
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("unsupported type '%s'", body.Type)})
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body.Data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[r.PathValue("jobId")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "job not found"})
+		return
+	}
+
+	blob := Blob{
+		ID:   fmt.Sprintf("%s-blob-%d", job.ID, len(job.Blobs)+1),
+		Name: body.Name,
+		Type: body.Type,
+		Data: data,
+	}
+	job.Blobs = append(job.Blobs, blob)
+
+	writeJSON(w, http.StatusCreated, map[string]string{"id": blob.ID})
+}
+
+// decodeBody decodes a JSON request body, writing an error response and
+// returning false if it can't. Like the control plane, bodies over 1 MiB are
+// rejected.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"message": "Request body is too large"})
+		return false
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,138 @@
+package inferabletest_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inferable "github.com/inferablehq/inferable/sdk-go"
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+type GreetInput struct {
+	Name string `json:"name"`
+}
+
+func greet(input GreetInput, ctx inferable.ContextInput) (string, error) {
+	if input.Name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	return "Hello " + input.Name, nil
+}
+
+func newClient(t *testing.T, server *inferabletest.Server) *inferable.Inferable {
+	client, err := inferable.New(inferable.InferableOptions{
+		APIEndpoint:  server.URL,
+		APISecret:    server.Secret,
+		MachineID:    "test-machine",
+		PollWaitTime: 1,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.Tools.Register(inferable.Tool{
+		Func:        greet,
+		Name:        "greet",
+		Description: "Greets someone",
+	}))
+
+	return client
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	server := inferabletest.NewServer(t)
+	client := newClient(t, server)
+
+	require.NoError(t, client.Tools.Listen())
+	defer client.Tools.Unlisten()
+
+	tool, ok := server.Tool("greet")
+	require.True(t, ok)
+	assert.Equal(t, "Greets someone", tool.Description)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {"name": {"type": "string"}},
+		"required": ["name"],
+		"additionalProperties": false
+	}`, tool.Schema)
+	assert.Equal(t, 1, server.Registrations("test-machine"))
+
+	resolved := server.Enqueue(t, "greet", GreetInput{Name: "Ada"})
+	rejected := server.Enqueue(t, "greet", GreetInput{})
+
+	result := server.WaitForResult(t, resolved)
+	assert.Equal(t, "resolution", result.ResultType)
+	var greeting string
+	require.NoError(t, result.Decode(&greeting))
+	assert.Equal(t, "Hello Ada", greeting)
+
+	result = server.WaitForResult(t, rejected)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.JSONEq(t, `"name is required"`, string(result.Result))
+
+	job, ok := server.Job(resolved)
+	require.True(t, ok)
+	assert.Equal(t, inferabletest.StatusSuccess, job.Status)
+	assert.Equal(t, "test-machine", job.MachineID)
+}
+
+func TestServerRejectsWrongSecret(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	client, err := inferable.New(inferable.InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "sk_wrong",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Tools.Register(inferable.Tool{Func: greet, Name: "greet"}))
+
+	assert.Error(t, client.Tools.Listen())
+	assert.Empty(t, server.Tools())
+}
+
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertSchemaSnapshot(t *testing.T) {
+	client, err := inferable.New(inferable.InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Tools.Register(inferable.Tool{Name: "greet", Func: greet}))
+
+	path := filepath.Join(t.TempDir(), "schemas.json")
+
+	// Written on first use, then matched
+	inferabletest.AssertSchemaSnapshot(t, client.Tools, path)
+	assert.FileExists(t, path)
+	inferabletest.AssertSchemaSnapshot(t, client.Tools, path)
+
+	require.NoError(t, client.Tools.Unregister("greet"))
+	require.NoError(t, client.Tools.Register(inferable.Tool{
+		Name: "greet",
+		Func: func(input struct{}, ctx inferable.ContextInput) string { return "hello" },
+	}))
+
+	recorder := &recordingT{TB: t}
+	inferabletest.AssertSchemaSnapshot(recorder, client.Tools, path)
+	require.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], "BREAKING greet input.name: property removed")
+
+	// Accepting the change rewrites the snapshot
+	t.Setenv(inferabletest.UpdateSnapshotsEnv, "1")
+	inferabletest.AssertSchemaSnapshot(t, client.Tools, path)
+	t.Setenv(inferabletest.UpdateSnapshotsEnv, "")
+	inferabletest.AssertSchemaSnapshot(t, client.Tools, path)
+}
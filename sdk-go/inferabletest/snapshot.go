@@ -0,0 +1,80 @@
+package inferabletest
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/schemadiff"
+)
+
+// UpdateSnapshotsEnv is the environment variable that, when set to "1",
+// makes AssertSchemaSnapshot rewrite snapshots instead of comparing them.
+const UpdateSnapshotsEnv = "INFERABLE_UPDATE_SNAPSHOTS"
+
+// SchemaSnapshotter is implemented by the agents of an Inferable instance,
+// such as client.Tools.
+type SchemaSnapshotter interface {
+	SchemaSnapshot() ([]byte, error)
+}
+
+// AssertSchemaSnapshot fails the test if the agent's tool schemas differ from
+// the snapshot committed at path, reporting which changes are breaking. The
+// snapshot is written if it doesn't exist, or if UpdateSnapshotsEnv is set.
+//
+// Example:
+//
+//	func TestSchemas(t *testing.T) {
+//	    client, _ := inferable.New(inferable.InferableOptions{...})
+//	    registerTools(client)
+//
+//	    inferabletest.AssertSchemaSnapshot(t, client.Tools, "testdata/schemas.json")
+//	}
+func AssertSchemaSnapshot(t testing.TB, agent SchemaSnapshotter, path string) {
+	t.Helper()
+
+	current, err := agent.SchemaSnapshot()
+	if err != nil {
+		t.Fatalf("failed to create schema snapshot: %v", err)
+	}
+
+	committed, err := os.ReadFile(path)
+	if os.IsNotExist(err) || os.Getenv(UpdateSnapshotsEnv) == "1" {
+		if err := os.WriteFile(path, current, 0644); err != nil {
+			t.Fatalf("failed to write schema snapshot: %v", err)
+		}
+		t.Logf("wrote schema snapshot to %s", path)
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to read schema snapshot: %v", err)
+	}
+
+	if bytes.Equal(committed, current) {
+		return
+	}
+
+	old, err := schemadiff.Parse(committed)
+	if err != nil {
+		t.Fatalf("failed to read schema snapshot %s: %v", path, err)
+	}
+	new, err := schemadiff.Parse(current)
+	if err != nil {
+		t.Fatalf("failed to read current schemas: %v", err)
+	}
+
+	changes := schemadiff.Diff(old, new)
+	if len(changes) == 0 {
+		// Only formatting or descriptions differ
+		changes = append(changes, schemadiff.Change{Message: "schemas changed"})
+	}
+
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = "  " + change.String()
+	}
+
+	t.Errorf("tool schemas differ from snapshot %s:\n%s\nrun with %s=1 to update the snapshot",
+		path, strings.Join(lines, "\n"), UpdateSnapshotsEnv)
+}
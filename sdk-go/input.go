@@ -0,0 +1,125 @@
+package inferable
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/invopop/jsonschema"
+)
+
+// Property that inputs which aren't structs, such as strings, slices and
+// maps, are wrapped in, as the control plane only calls tools with objects.
+const inputValueKey = "value"
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// wrapsInput reports whether inputs of a type are wrapped in an object. Structs
+// decoded from text, such as time.Time, are wrapped along with non-structs.
+func wrapsInput(t reflect.Type) bool {
+	if t == rawMessageType {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Struct || implements(t, textUnmarshalerType)
+}
+
+// reflectInputSchema returns the schema for a tool's input type. Structs are
+// called with objects matching their fields, json.RawMessage with any
+// object, and other types with an object holding the input as its "value".
+func reflectInputSchema(name string, t reflect.Type, options schemaOptions) (*jsonschema.Schema, error) {
+	if t == rawMessageType {
+		return &jsonschema.Schema{
+			Version: jsonschema.Version,
+			Type:    "object",
+		}, nil
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil, fmt.Errorf("tool '%s' first argument must be a type that can be decoded from JSON, got %s", name, t)
+	}
+
+	schema, err := reflectSchema(name, t, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wrapsInput(t) {
+		schema.AdditionalProperties = jsonschema.FalseSchema
+		return schema, nil
+	}
+
+	version := schema.Version
+	schema.Version = ""
+
+	properties := jsonschema.NewProperties()
+	properties.Set(inputValueKey, schema)
+
+	return &jsonschema.Schema{
+		Version:              version,
+		Type:                 "object",
+		Properties:           properties,
+		Required:             []string{inputValueKey},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}, nil
+}
+
+// DecodeInput decodes the input of a call into v, a pointer to the tool's
+// input type, unwrapping inputs that aren't structs from their "value"
+// property. Enums registered with RegisterEnum aren't checked; use
+// ContextInput.DecodeInput within tools.
+func DecodeInput(input json.RawMessage, v interface{}) error {
+	return decodeInput(input, v, nil)
+}
+
+// DecodeInput decodes the input of a call like the package's DecodeInput,
+// also checking the enums registered with the tool's agent. It is used by
+// code generated by inferable-gen.
+func (c ContextInput) DecodeInput(input json.RawMessage, v interface{}) error {
+	var enums map[reflect.Type][]interface{}
+	if c.enums != nil {
+		enums = *c.enums
+	}
+	return decodeInput(input, v, enums)
+}
+
+// decodeInput decodes an input and checks that the values of enum types,
+// registered or implementing Enum, are valid.
+func decodeInput(input json.RawMessage, v interface{}, enums map[reflect.Type][]interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return fmt.Errorf("input must be decoded into a pointer, got %T", v)
+	}
+
+	if !wrapsInput(t.Elem()) {
+		if err := json.Unmarshal(input, v); err != nil {
+			return err
+		}
+		return checkEnums(reflect.ValueOf(v), input, "$", enums)
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(input, &wrapper); err != nil {
+		return err
+	}
+
+	value, ok := wrapper[inputValueKey]
+	if !ok {
+		return fmt.Errorf("input is missing property '%s'", inputValueKey)
+	}
+
+	if err := json.Unmarshal(value, v); err != nil {
+		return err
+	}
+	return checkEnums(reflect.ValueOf(v), value, "$."+inputValueKey, enums)
+}
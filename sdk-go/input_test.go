@@ -0,0 +1,108 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonStructInputs(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "search",
+		Func: func(query string, ctx ContextInput) string { return "results for " + query },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "sum",
+		Func: func(numbers []int, ctx ContextInput) int {
+			total := 0
+			for _, n := range numbers {
+				total += n
+			}
+			return total
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "labels",
+		Func: func(labels map[string]string, ctx ContextInput) int { return len(labels) },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "optional",
+		Func: func(limit *int, ctx ContextInput) bool { return limit == nil },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "raw",
+		Func: func(input json.RawMessage, ctx ContextInput) string { return string(input) },
+	}))
+
+	assert.ErrorContains(t, i.Tools.Register(Tool{
+		Name: "callback",
+		Func: func(callback func(), ctx ContextInput) string { return "" },
+	}), "tool 'callback' first argument must be a type that can be decoded from JSON")
+
+	schema, err := json.Marshal(i.Tools.Tools()["search"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {"value": {"type": "string"}},
+		"required": ["value"],
+		"additionalProperties": false
+	}`, string(schema))
+
+	schema, err = json.Marshal(i.Tools.Tools()["sum"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {"value": {"type": "array", "items": {"type": "integer"}}},
+		"required": ["value"],
+		"additionalProperties": false
+	}`, string(schema))
+
+	schema, err = json.Marshal(i.Tools.Tools()["raw"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`, string(schema))
+
+	invoke := func(name, input string) Result {
+		result, err := i.Tools.Invoke(context.Background(), name, json.RawMessage(input), ContextInput{})
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, "results for shoes", invoke("search", `{"value": "shoes"}`).Value)
+	assert.Equal(t, 6, invoke("sum", `{"value": [1, 2, 3]}`).Value)
+	assert.Equal(t, 2, invoke("labels", `{"value": {"env": "prod", "team": "core"}}`).Value)
+	assert.Equal(t, true, invoke("optional", `{"value": null}`).Value)
+	assert.Equal(t, false, invoke("optional", `{"value": 10}`).Value)
+	assert.Equal(t, `{"anything": [1, 2]}`, invoke("raw", `{"anything": [1, 2]}`).Value)
+
+	rejected := invoke("search", `{"query": "shoes"}`)
+	assert.Equal(t, ResultTypeRejection, rejected.Type)
+	assert.Equal(t, "input is missing property 'value'", rejected.Value)
+
+	rejected = invoke("sum", `{"value": "1, 2, 3"}`)
+	assert.Equal(t, ResultTypeRejection, rejected.Type)
+}
+
+func TestDecodeInput(t *testing.T) {
+	var input EchoInput
+	require.NoError(t, DecodeInput(json.RawMessage(`{"Input": "hello"}`), &input))
+	assert.Equal(t, "hello", input.Input)
+
+	var query string
+	require.NoError(t, DecodeInput(json.RawMessage(`{"value": "hello"}`), &query))
+	assert.Equal(t, "hello", query)
+
+	assert.ErrorContains(t, DecodeInput(json.RawMessage(`{}`), query), "input must be decoded into a pointer")
+}
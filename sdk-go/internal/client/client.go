@@ -1,22 +1,25 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // Client represents an Inferable API client
 type Client struct {
 	endpoint   string
-	secret     string
+	secret     func() (string, error)
 	httpClient *http.Client
 }
 
 type ClientOptions struct {
 	Endpoint string
-	Secret   string
+	// Secret is called before each request to get the bearer token
+	Secret func() (string, error)
 }
 
 // NewClient creates a new Inferable API client
@@ -38,6 +41,9 @@ type FetchDataOptions struct {
 	QueryParams map[string]string
 	Body        string
 	Method      string
+	// Timeout bounds the whole request, including reading the response body.
+	// A zero value means no timeout.
+	Timeout time.Duration
 }
 
 func (c *Client) FetchData(options FetchDataOptions) (string, http.Header, error, int) {
@@ -47,12 +53,23 @@ func (c *Client) FetchData(options FetchDataOptions) (string, http.Header, error
 		return "", nil, fmt.Errorf("invalid URL: %s", fullURL), -1
 	}
 
-	req, err := http.NewRequest(options.Method, fullURL, strings.NewReader(options.Body))
+	ctx := context.Background()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, options.Method, fullURL, strings.NewReader(options.Body))
 	if err != nil {
 		return "", nil, fmt.Errorf("error creating request: %v", err), -1
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.secret)
+	secret, err := c.secret()
+	if err != nil {
+		return "", nil, err, -1
+	}
+	req.Header.Set("Authorization", "Bearer "+secret)
 
 	// Add custom headers
 	for key, value := range options.Headers {
@@ -0,0 +1,36 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	cache := New[int](2)
+
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+
+	// Getting a marks it as recently used, so b is evicted
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	cache.Add("c", 3)
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len())
+
+	cache.Add("a", 10)
+	value, _ = cache.Get("a")
+	assert.Equal(t, 10, value)
+
+	cache.Remove("a")
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.Len())
+}
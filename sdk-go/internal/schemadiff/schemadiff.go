@@ -0,0 +1,311 @@
+// Package schemadiff compares snapshots of tool schemas and classifies the
+// changes between them as breaking or not.
+//
+// A change to an input schema is breaking when input that was valid before
+// may be rejected after it, such as a removed property, a newly required
+// property, a narrowed type or a narrowed enum. A change to an output schema
+// is breaking when consumers may receive results they didn't before, such as
+// a removed property, a property no longer required or a widened enum.
+package schemadiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is a difference between two snapshots.
+type Change struct {
+	Tool string
+	// Location of the change within the tool, e.g. input.city or output[]
+	Path     string
+	Message  string
+	Breaking bool
+}
+
+func (c Change) String() string {
+	location := c.Tool
+	if c.Path != "" {
+		location += " " + c.Path
+	}
+
+	if c.Breaking {
+		return fmt.Sprintf("BREAKING %s: %s", location, c.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, c.Message)
+}
+
+// Snapshot is the set of tool schemas of an agent, keyed by tool name.
+type Snapshot map[string]Tool
+
+// Tool is a tool's entry in a snapshot.
+type Tool struct {
+	Name   string      `json:"name"`
+	Input  interface{} `json:"input"`
+	Output interface{} `json:"output,omitempty"`
+}
+
+// Parse decodes a snapshot file.
+func Parse(data []byte) (Snapshot, error) {
+	snapshot := Snapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse schema snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// HasBreaking reports whether any of the changes is breaking.
+func HasBreaking(changes []Change) bool {
+	for _, change := range changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changes from old to new, ordered by tool and path.
+func Diff(old, new Snapshot) []Change {
+	d := &differ{}
+
+	for _, name := range keys(old, new) {
+		oldTool, inOld := old[name]
+		newTool, inNew := new[name]
+
+		d.tool = name
+		switch {
+		case !inNew:
+			d.add("", "tool removed", true)
+		case !inOld:
+			d.add("", "tool added", false)
+		default:
+			d.compare(oldTool.Input, newTool.Input, "input", true)
+
+			switch {
+			case oldTool.Output == nil && newTool.Output != nil:
+				d.add("output", "output schema added", false)
+			case oldTool.Output != nil && newTool.Output == nil:
+				d.add("output", "output schema removed", false)
+			case oldTool.Output != nil:
+				d.compare(oldTool.Output, newTool.Output, "output", false)
+			}
+		}
+	}
+
+	return d.changes
+}
+
+type differ struct {
+	tool    string
+	changes []Change
+}
+
+func (d *differ) add(path, message string, breaking bool) {
+	d.changes = append(d.changes, Change{Tool: d.tool, Path: path, Message: message, Breaking: breaking})
+}
+
+// compare records the changes between two schemas. For inputs, accepting
+// fewer values is breaking; for outputs, producing more values is.
+func (d *differ) compare(old, new interface{}, path string, input bool) {
+	oldSchema, _ := old.(map[string]interface{})
+	newSchema, _ := new.(map[string]interface{})
+	if oldSchema == nil || newSchema == nil {
+		if !reflect.DeepEqual(old, new) {
+			d.add(path, "schema changed", true)
+		}
+		return
+	}
+
+	d.compareTypes(oldSchema, newSchema, path, input)
+	d.compareEnums(oldSchema, newSchema, path, input)
+	d.compareProperties(oldSchema, newSchema, path, input)
+
+	if oldItems, ok := oldSchema["items"]; ok {
+		if newItems, ok := newSchema["items"]; ok {
+			d.compare(oldItems, newItems, path+"[]", input)
+		}
+	}
+
+	oldAdditional, oldOk := oldSchema["additionalProperties"].(map[string]interface{})
+	newAdditional, newOk := newSchema["additionalProperties"].(map[string]interface{})
+	if oldOk && newOk {
+		d.compare(oldAdditional, newAdditional, path+".*", input)
+	}
+}
+
+func (d *differ) compareTypes(old, new map[string]interface{}, path string, input bool) {
+	oldTypes := types(old)
+	newTypes := types(new)
+	if reflect.DeepEqual(oldTypes, newTypes) {
+		return
+	}
+
+	message := fmt.Sprintf("type changed from %s to %s", describeTypes(oldTypes), describeTypes(newTypes))
+	if input {
+		d.add(path, message, !covers(newTypes, oldTypes))
+	} else {
+		d.add(path, message, !covers(oldTypes, newTypes))
+	}
+}
+
+func (d *differ) compareEnums(old, new map[string]interface{}, path string, input bool) {
+	oldEnum, oldOk := old["enum"].([]interface{})
+	newEnum, newOk := new["enum"].([]interface{})
+
+	switch {
+	case !oldOk && !newOk:
+		return
+	case !oldOk:
+		d.add(path, fmt.Sprintf("values restricted to %s", describeValues(newEnum)), input)
+		return
+	case !newOk:
+		d.add(path, "values no longer restricted", !input)
+		return
+	}
+
+	removed := difference(oldEnum, newEnum)
+	added := difference(newEnum, oldEnum)
+
+	if len(removed) > 0 {
+		d.add(path, fmt.Sprintf("enum values removed: %s", describeValues(removed)), input)
+	}
+	if len(added) > 0 {
+		d.add(path, fmt.Sprintf("enum values added: %s", describeValues(added)), !input)
+	}
+}
+
+func (d *differ) compareProperties(old, new map[string]interface{}, path string, input bool) {
+	oldProperties, _ := old["properties"].(map[string]interface{})
+	newProperties, _ := new["properties"].(map[string]interface{})
+	oldRequired := required(old)
+	newRequired := required(new)
+
+	for _, name := range keys(oldProperties, newProperties) {
+		propertyPath := path + "." + name
+		oldProperty, inOld := oldProperties[name]
+		newProperty, inNew := newProperties[name]
+
+		switch {
+		case !inNew:
+			d.add(propertyPath, "property removed", true)
+			continue
+		case !inOld && newRequired[name]:
+			d.add(propertyPath, "required property added", input)
+			continue
+		case !inOld:
+			d.add(propertyPath, "optional property added", false)
+			continue
+		}
+
+		switch {
+		case newRequired[name] && !oldRequired[name]:
+			d.add(propertyPath, "property is now required", input)
+		case oldRequired[name] && !newRequired[name]:
+			d.add(propertyPath, "property is no longer required", !input)
+		}
+
+		d.compare(oldProperty, newProperty, propertyPath, input)
+	}
+}
+
+// types returns the sorted types a schema allows, or nil if unconstrained.
+func types(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		result := []string{}
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				result = append(result, name)
+			}
+		}
+		sort.Strings(result)
+		return result
+	}
+	return nil
+}
+
+// covers reports whether the types in a allow every value the types in b do.
+func covers(a, b []string) bool {
+	if a == nil {
+		return true
+	}
+	if b == nil {
+		return false
+	}
+
+	allowed := map[string]bool{}
+	for _, t := range a {
+		allowed[t] = true
+	}
+	for _, t := range b {
+		if !allowed[t] && !(t == "integer" && allowed["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeTypes(types []string) string {
+	if types == nil {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+func describeValues(values []interface{}) string {
+	descriptions := make([]string, len(values))
+	for i, value := range values {
+		data, _ := json.Marshal(value)
+		descriptions[i] = string(data)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func required(schema map[string]interface{}) map[string]bool {
+	result := map[string]bool{}
+	names, _ := schema["required"].([]interface{})
+	for _, name := range names {
+		if name, ok := name.(string); ok {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+// difference returns the values in a that aren't in b.
+func difference(a, b []interface{}) []interface{} {
+	result := []interface{}{}
+	for _, value := range a {
+		found := false
+		for _, other := range b {
+			if reflect.DeepEqual(value, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// keys returns the sorted union of the keys of two maps.
+func keys[V any](a, b map[string]V) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, m := range []map[string]V{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, key)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,182 @@
+package schemadiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const base = `{
+  "getOrder": {
+    "name": "getOrder",
+    "input": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "string"},
+        "status": {"type": "string", "enum": ["open", "closed"]},
+        "limit": {"type": "integer"}
+      },
+      "required": ["id"],
+      "additionalProperties": false
+    },
+    "output": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "string"},
+        "items": {"type": "array", "items": {"type": "string"}}
+      },
+      "required": ["id", "items"]
+    }
+  },
+  "ping": {
+    "name": "ping",
+    "input": {"type": "object", "properties": {}}
+  }
+}`
+
+func TestDiff(t *testing.T) {
+	old, err := Parse([]byte(base))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		edit    func(s Snapshot)
+		changes []Change
+	}{
+		{
+			name:    "unchanged",
+			edit:    func(s Snapshot) {},
+			changes: nil,
+		},
+		{
+			name:    "tool removed",
+			edit:    func(s Snapshot) { delete(s, "ping") },
+			changes: []Change{{Tool: "ping", Message: "tool removed", Breaking: true}},
+		},
+		{
+			name:    "tool added",
+			edit:    func(s Snapshot) { s["pong"] = s["ping"] },
+			changes: []Change{{Tool: "pong", Message: "tool added"}},
+		},
+		{
+			name: "input property removed",
+			edit: func(s Snapshot) { delete(properties(s, "input"), "limit") },
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.limit", Message: "property removed", Breaking: true},
+			},
+		},
+		{
+			name: "required property added",
+			edit: func(s Snapshot) {
+				properties(s, "input")["customer"] = map[string]interface{}{"type": "string"}
+				schema(s, "input")["required"] = []interface{}{"id", "customer"}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.customer", Message: "required property added", Breaking: true},
+			},
+		},
+		{
+			name: "optional property added",
+			edit: func(s Snapshot) {
+				properties(s, "input")["customer"] = map[string]interface{}{"type": "string"}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.customer", Message: "optional property added"},
+			},
+		},
+		{
+			name: "existing property required",
+			edit: func(s Snapshot) { schema(s, "input")["required"] = []interface{}{"id", "limit"} },
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.limit", Message: "property is now required", Breaking: true},
+			},
+		},
+		{
+			name: "enum narrowed",
+			edit: func(s Snapshot) {
+				properties(s, "input")["status"].(map[string]interface{})["enum"] = []interface{}{"open", "pending"}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.status", Message: `enum values removed: "closed"`, Breaking: true},
+				{Tool: "getOrder", Path: "input.status", Message: `enum values added: "pending"`},
+			},
+		},
+		{
+			name: "type narrowed",
+			edit: func(s Snapshot) {
+				properties(s, "input")["id"] = map[string]interface{}{"type": "integer"}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.id", Message: "type changed from string to integer", Breaking: true},
+			},
+		},
+		{
+			name: "type widened",
+			edit: func(s Snapshot) {
+				properties(s, "input")["limit"] = map[string]interface{}{"type": "number"}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "input.limit", Message: "type changed from integer to number"},
+			},
+		},
+		{
+			name: "output property removed",
+			edit: func(s Snapshot) { delete(properties(s, "output"), "items") },
+			changes: []Change{
+				{Tool: "getOrder", Path: "output.items", Message: "property removed", Breaking: true},
+			},
+		},
+		{
+			name: "output property no longer required",
+			edit: func(s Snapshot) { schema(s, "output")["required"] = []interface{}{"id"} },
+			changes: []Change{
+				{Tool: "getOrder", Path: "output.items", Message: "property is no longer required", Breaking: true},
+			},
+		},
+		{
+			name: "output items changed",
+			edit: func(s Snapshot) {
+				properties(s, "output")["items"].(map[string]interface{})["items"] = map[string]interface{}{"type": []interface{}{"string", "null"}}
+			},
+			changes: []Change{
+				{Tool: "getOrder", Path: "output.items[]", Message: "type changed from string to null|string", Breaking: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			new, err := Parse([]byte(base))
+			require.NoError(t, err)
+			tt.edit(new)
+
+			changes := Diff(old, new)
+			assert.Equal(t, tt.changes, changes)
+			assert.Equal(t, HasBreaking(tt.changes), HasBreaking(changes))
+		})
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	assert.Equal(t, "BREAKING getOrder input.id: property removed",
+		Change{Tool: "getOrder", Path: "input.id", Message: "property removed", Breaking: true}.String())
+	assert.Equal(t, "ping: tool added", Change{Tool: "ping", Message: "tool added"}.String())
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte(`[]`))
+	assert.Error(t, err)
+}
+
+func schema(s Snapshot, kind string) map[string]interface{} {
+	tool := s["getOrder"]
+	if kind == "input" {
+		return tool.Input.(map[string]interface{})
+	}
+	return tool.Output.(map[string]interface{})
+}
+
+func properties(s Snapshot, kind string) map[string]interface{} {
+	return schema(s, kind)["properties"].(map[string]interface{})
+}
@@ -1,19 +1,36 @@
 package util
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math/big"
+	mathrand "math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 const (
 	MachineIDFile = "inferable_machine_id.json"
 )
 
+const machineIDCharset = "abcdefghijklmnopqrstuvwxyz"
+
+// Guards machine ID files against concurrent access from within the process.
+// Concurrent access from other processes is handled by linking a fully
+// written file into place.
+var machineIDMutex sync.Mutex
+
+type machineIDFileContents struct {
+	MachineID string `json:"machineId"`
+}
+
 func GetMachineID() string {
 	hostname, _ := os.Hostname()
 	cpuInfo := runtime.GOARCH + runtime.GOOS + runtime.Version()
@@ -30,14 +47,146 @@ func GenerateMachineID(length int) string {
 		seed += int64(char)
 	}
 
-	r := rand.New(rand.NewSource(seed))
-	const charset = "abcdefghijklmnopqrstuvwxyz"
+	r := mathrand.New(mathrand.NewSource(seed))
 
 	var sb strings.Builder
 	sb.Grow(length)
 	for i := 0; i < length; i++ {
-		sb.WriteByte(charset[r.Intn(len(charset))])
+		sb.WriteByte(machineIDCharset[r.Intn(len(machineIDCharset))])
 	}
 
 	return fmt.Sprintf("go-%s", sb.String())
 }
+
+// RandomMachineID generates a machine ID from a cryptographically secure
+// random source.
+func RandomMachineID(length int) (string, error) {
+	var sb strings.Builder
+	sb.Grow(length)
+	max := big.NewInt(int64(len(machineIDCharset)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random machine ID: %v", err)
+		}
+		sb.WriteByte(machineIDCharset[n.Int64()])
+	}
+
+	return fmt.Sprintf("go-%s", sb.String()), nil
+}
+
+// DefaultMachineIDPath returns the location of the machine ID file within the
+// user's configuration directory.
+func DefaultMachineIDPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %v", err)
+	}
+
+	return filepath.Join(dir, "inferable", MachineIDFile), nil
+}
+
+// LoadOrCreateMachineID returns the machine ID stored at path. If the file
+// doesn't exist (or can't be parsed), a random machine ID of the given length
+// is generated and persisted for subsequent calls. Other errors reading the
+// file are returned rather than replacing the machine ID.
+func LoadOrCreateMachineID(path string, length int) (string, error) {
+	machineIDMutex.Lock()
+	defer machineIDMutex.Unlock()
+
+	machineID, corrupt, err := readMachineID(path)
+	if err == nil {
+		return machineID, nil
+	}
+	if !corrupt && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read machine ID file: %v", err)
+	}
+
+	machineID, err = RandomMachineID(length)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create machine ID directory: %v", err)
+	}
+
+	tmp, err := writeMachineIDTemp(filepath.Dir(path), machineID)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	if corrupt {
+		if err := os.Rename(tmp, path); err != nil {
+			return "", fmt.Errorf("failed to replace machine ID file: %v", err)
+		}
+		return machineID, nil
+	}
+
+	// Linking fails if another process created the file first, in which case
+	// its machine ID wins.
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			machineID, _, err := readMachineID(path)
+			return machineID, err
+		}
+		// Some filesystems don't support hard links
+		if err := os.Rename(tmp, path); err != nil {
+			return "", fmt.Errorf("failed to write machine ID file: %v", err)
+		}
+	}
+
+	return machineID, nil
+}
+
+// readMachineID returns the machine ID stored at path, and whether the file
+// was read but doesn't contain a machine ID.
+func readMachineID(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	var contents machineIDFileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return "", true, fmt.Errorf("failed to parse machine ID file: %v", err)
+	}
+
+	if contents.MachineID == "" {
+		return "", true, fmt.Errorf("machine ID file %s is empty", path)
+	}
+
+	return contents.MachineID, false, nil
+}
+
+func writeMachineIDTemp(dir string, machineID string) (string, error) {
+	data, err := json.Marshal(machineIDFileContents{MachineID: machineID})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal machine ID: %v", err)
+	}
+
+	f, err := os.CreateTemp(dir, MachineIDFile+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create machine ID file: %v", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write machine ID file: %v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to sync machine ID file: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to close machine ID file: %v", err)
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateMachineID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", MachineIDFile)
+
+	id, err := LoadOrCreateMachineID(path, 16)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "go-"))
+	assert.Len(t, id, len("go-")+16)
+
+	// Subsequent calls reuse the stored ID
+	again, err := LoadOrCreateMachineID(path, 16)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files should be cleaned up")
+}
+
+func TestLoadOrCreateMachineIDConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), MachineIDFile)
+
+	ids := make([]string, 20)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := LoadOrCreateMachineID(path, 16)
+			assert.NoError(t, err)
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+}
+
+func TestLoadOrCreateMachineIDReplacesCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), MachineIDFile)
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+
+	id, err := LoadOrCreateMachineID(path, 16)
+	require.NoError(t, err)
+
+	stored, _, err := readMachineID(path)
+	require.NoError(t, err)
+	assert.Equal(t, id, stored)
+}
+
+func TestLoadOrCreateMachineIDUnreadableFile(t *testing.T) {
+	// A directory in place of the file can't be read, but isn't missing
+	path := filepath.Join(t.TempDir(), MachineIDFile)
+	require.NoError(t, os.Mkdir(path, 0700))
+
+	_, err := LoadOrCreateMachineID(path, 16)
+	assert.ErrorContains(t, err, "failed to read machine ID file")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestLoadOrCreateMachineIDUnavailableStorage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+
+	_, err := LoadOrCreateMachineID(filepath.Join(file, MachineIDFile), 16)
+	assert.Error(t, err)
+}
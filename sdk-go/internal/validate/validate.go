@@ -0,0 +1,304 @@
+// Package validate checks JSON values against the subset of JSON Schema
+// produced when reflecting Go types.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON schema.
+type Schema struct {
+	root interface{}
+	// AllowNull accepts null wherever a value is expected. Go encodes nil
+	// slices, maps and pointers as null, which reflected schemas don't allow.
+	AllowNull bool
+}
+
+// Compile prepares a schema for validation. The schema may be any value that
+// marshals to a JSON schema, such as a *jsonschema.Schema.
+func Compile(schema interface{}) (*Schema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %v", err)
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+
+	switch root.(type) {
+	case bool, map[string]interface{}:
+	default:
+		return nil, fmt.Errorf("schema must be an object or a boolean")
+	}
+
+	return &Schema{root: root}, nil
+}
+
+// Validate checks that value, once encoded as JSON, matches the schema.
+func (s *Schema) Validate(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %v", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("failed to parse value: %v", err)
+	}
+
+	return s.validate(s.root, decoded, "$")
+}
+
+func (s *Schema) validate(schema interface{}, value interface{}, path string) error {
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			return fmt.Errorf("%s: no value is allowed", path)
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateObject(schema, value, path)
+	default:
+		return nil
+	}
+}
+
+func (s *Schema) validateObject(schema map[string]interface{}, value interface{}, path string) error {
+	if value == nil && s.AllowNull {
+		return nil
+	}
+
+	if t, ok := schema["type"]; ok {
+		if err := validateType(t, value, path); err != nil {
+			return err
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		return fmt.Errorf("%s: must be %s", path, encode(c))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		if !contains(enum, value) {
+			options := make([]string, len(enum))
+			for i, e := range enum {
+				options[i] = encode(e)
+			}
+			return fmt.Errorf("%s: must be one of %s", path, strings.Join(options, ", "))
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := s.validate(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		if s.matches(anyOf, value, path) == 0 {
+			return fmt.Errorf("%s: does not match any of the allowed schemas", path)
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if s.matches(oneOf, value, path) != 1 {
+			return fmt.Errorf("%s: must match exactly one of the allowed schemas", path)
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			return fmt.Errorf("%s: must be at least %v characters", path, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			return fmt.Errorf("%s: must be at most %v characters", path, max)
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && value < min {
+			return fmt.Errorf("%s: must be at least %v", path, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && value > max {
+			return fmt.Errorf("%s: must be at most %v", path, max)
+		}
+	case []interface{}:
+		return s.validateArray(schema, value, path)
+	case map[string]interface{}:
+		return s.validateProperties(schema, value, path)
+	}
+
+	return nil
+}
+
+func (s *Schema) validateArray(schema map[string]interface{}, value []interface{}, path string) error {
+	length := float64(len(value))
+	if min, ok := schema["minItems"].(float64); ok && length < min {
+		return fmt.Errorf("%s: must have at least %v items", path, min)
+	}
+	if max, ok := schema["maxItems"].(float64); ok && length > max {
+		return fmt.Errorf("%s: must have at most %v items", path, max)
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			if err := s.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateProperties(schema map[string]interface{}, value map[string]interface{}, path string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, exists := value[name]; !exists {
+					return fmt.Errorf("%s: missing required property '%s'", path, name)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// Sort the keys so that the first error reported is deterministic
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := path + "." + key
+		if property, ok := properties[key]; ok {
+			if err := s.validate(property, value[key], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasAdditional {
+			continue
+		}
+
+		if allowed, ok := additional.(bool); ok && !allowed {
+			return fmt.Errorf("%s: unexpected property '%s'", path, key)
+		}
+		if err := s.validate(additional, value[key], propertyPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matches returns how many of the schemas the value matches.
+func (s *Schema) matches(schemas []interface{}, value interface{}, path string) int {
+	count := 0
+	for _, sub := range schemas {
+		if s.validate(sub, value, path) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func validateType(t interface{}, value interface{}, path string) error {
+	types := []string{}
+	switch t := t.(type) {
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+
+	for _, name := range types {
+		if hasType(name, value) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), typeOf(value))
+}
+
+func hasType(name string, value interface{}) bool {
+	switch name {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func typeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
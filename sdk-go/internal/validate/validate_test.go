@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile(map[string]interface{}{
+		"type":     "object",
+		"required": []string{"id", "count"},
+		"properties": map[string]interface{}{
+			"id":    map[string]interface{}{"type": "string", "minLength": 1},
+			"count": map[string]interface{}{"type": "integer", "minimum": 0},
+			"state": map[string]interface{}{"enum": []string{"open", "closed"}},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"owner": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "null"},
+				},
+			},
+		},
+		"additionalProperties": false,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"valid", map[string]interface{}{"id": "a", "count": 1, "state": "open", "tags": []string{"x"}}, ""},
+		{"null owner", map[string]interface{}{"id": "a", "count": 1, "owner": nil}, ""},
+		{"wrong type", "order", "$: expected object, got string"},
+		{"missing required", map[string]interface{}{"id": "a"}, "$: missing required property 'count'"},
+		{"not an integer", map[string]interface{}{"id": "a", "count": 1.5}, "$.count: expected integer, got number"},
+		{"below minimum", map[string]interface{}{"id": "a", "count": -1}, "$.count: must be at least 0"},
+		{"too short", map[string]interface{}{"id": "", "count": 1}, "$.id: must be at least 1 characters"},
+		{"not in enum", map[string]interface{}{"id": "a", "count": 1, "state": "pending"}, `$.state: must be one of "open", "closed"`},
+		{"invalid item", map[string]interface{}{"id": "a", "count": 1, "tags": []int{1}}, "$.tags[0]: expected string, got integer"},
+		{"no matching schema", map[string]interface{}{"id": "a", "count": 1, "owner": 1}, "$.owner: does not match any of the allowed schemas"},
+		{"additional property", map[string]interface{}{"id": "a", "count": 1, "extra": true}, "$: unexpected property 'extra'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.value)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAllowNull(t *testing.T) {
+	schema, err := Compile(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tags": map[string]interface{}{"type": "array"},
+		},
+	})
+	require.NoError(t, err)
+
+	value := map[string]interface{}{"tags": nil}
+	assert.Error(t, schema.Validate(value))
+
+	schema.AllowNull = true
+	assert.NoError(t, schema.Validate(value))
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile(true)
+	assert.NoError(t, err)
+
+	_, err = Compile("object")
+	assert.Error(t, err)
+
+	schema, err := Compile(false)
+	require.NoError(t, err)
+	assert.Error(t, schema.Validate(1))
+}
@@ -0,0 +1,292 @@
+package inferable
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive rejections that
+	// open a circuit breaker unless configured otherwise.
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is how long a circuit breaker stays open unless
+	// configured otherwise.
+	DefaultBreakerCooldown = 30 * time.Second
+	// DefaultBreakerMaxWait is the longest a call waits for an open circuit
+	// with Wait set unless configured otherwise.
+	DefaultBreakerMaxWait = 10 * time.Second
+	// DefaultRateLimitMaxWait is the longest a call waits for a rate limit
+	// with Wait set unless configured otherwise.
+	DefaultRateLimitMaxWait = 10 * time.Second
+	// How often a waiting call checks whether a probe call has closed the
+	// circuit.
+	breakerProbeInterval = 100 * time.Millisecond
+)
+
+// RateLimit limits how often a tool is called, with a token bucket. Calls
+// over the limit are rejected with a message telling the caller when to try
+// again, or delayed if Wait is set.
+type RateLimit struct {
+	// Calls allowed per second on average. Required.
+	Rate float64
+	// Most calls allowed at once after a quiet period. Defaults to Rate,
+	// rounded up, or 1.
+	Burst int
+	// Delay calls over the limit until they are allowed, instead of
+	// rejecting them.
+	Wait bool
+	// Longest a call is delayed when Wait is set, beyond which it is
+	// rejected. Defaults to DefaultRateLimitMaxWait. Waiting calls count
+	// towards the service's concurrency, so long waits hold up other jobs.
+	MaxWait time.Duration
+}
+
+// CircuitBreaker stops calling a tool after repeated failures, giving the
+// system it depends on time to recover. After Threshold consecutive
+// rejections the circuit opens and calls are rejected, or delayed if Wait is
+// set, for Cooldown. A single probe call is then let through: if it succeeds
+// the circuit closes, otherwise it opens again.
+type CircuitBreaker struct {
+	// Consecutive rejections that open the circuit. Defaults to
+	// DefaultBreakerThreshold.
+	Threshold int
+	// How long the circuit stays open. Defaults to DefaultBreakerCooldown.
+	Cooldown time.Duration
+	// Delay calls while the circuit is open, instead of rejecting them.
+	Wait bool
+	// Longest a call is delayed when Wait is set, including across failed
+	// probes, beyond which it is rejected. Defaults to DefaultBreakerMaxWait.
+	MaxWait time.Duration
+}
+
+type rateLimiter struct {
+	name    string
+	rate    float64
+	burst   float64
+	wait    bool
+	maxWait time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(name string, config RateLimit) (*rateLimiter, error) {
+	if config.Rate <= 0 {
+		return nil, fmt.Errorf("rate limit for tool '%s' must be positive", name)
+	}
+	if config.Burst < 0 || config.MaxWait < 0 {
+		return nil, fmt.Errorf("rate limit burst and max wait for tool '%s' must not be negative", name)
+	}
+
+	burst := float64(config.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(config.Rate))
+	}
+
+	maxWait := config.MaxWait
+	if maxWait == 0 {
+		maxWait = DefaultRateLimitMaxWait
+	}
+
+	return &rateLimiter{
+		name:    name,
+		rate:    config.Rate,
+		burst:   burst,
+		wait:    config.Wait,
+		maxWait: maxWait,
+		now:     time.Now,
+		tokens:  burst,
+	}, nil
+}
+
+// reserve takes a token, returning how long the call must wait for it. If
+// the call can't wait that long, no token is taken and ok is false.
+func (r *rateLimiter) reserve() (delay time.Duration, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if !r.last.IsZero() {
+		r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0, true
+	}
+
+	delay = time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+	if !r.wait || delay > r.maxWait {
+		return delay, false
+	}
+
+	// Tokens go negative so that waiting calls queue up behind each other
+	r.tokens--
+	return delay, true
+}
+
+func (r *rateLimiter) middleware(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, call ToolCall) (Result, error) {
+		delay, ok := r.reserve()
+		if !ok {
+			return Result{
+				Value: fmt.Sprintf("Tool '%s' is rate limited. Try again in %s.", r.name, roundDelay(delay)),
+				Type:  ResultTypeRejection,
+			}, nil
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return Result{}, err
+		}
+
+		return next(ctx, call)
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	// A probe call is in flight
+	breakerHalfOpen
+)
+
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	wait      bool
+	maxWait   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(name string, config CircuitBreaker) (*circuitBreaker, error) {
+	if config.Threshold < 0 || config.Cooldown < 0 || config.MaxWait < 0 {
+		return nil, fmt.Errorf("circuit breaker threshold, cooldown and max wait for tool '%s' must not be negative", name)
+	}
+
+	threshold := config.Threshold
+	if threshold == 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	cooldown := config.Cooldown
+	if cooldown == 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	maxWait := config.MaxWait
+	if maxWait == 0 {
+		maxWait = DefaultBreakerMaxWait
+	}
+
+	return &circuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		wait:      config.Wait,
+		maxWait:   maxWait,
+		now:       time.Now,
+	}, nil
+}
+
+// allow reports whether a call may proceed, and if not how long until it
+// might. A call allowed while the circuit is open is the probe.
+func (b *circuitBreaker) allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		remaining := b.openedAt.Add(b.cooldown).Sub(b.now())
+		if remaining > 0 {
+			return false, remaining
+		}
+		b.state = breakerHalfOpen
+		return true, 0
+	case breakerHalfOpen:
+		return false, breakerProbeInterval
+	default:
+		return true, 0
+	}
+}
+
+// record updates the circuit with the outcome of an allowed call.
+func (b *circuitBreaker) record(result Result, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil && result.Type != ResultTypeRejection {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *circuitBreaker) middleware(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, call ToolCall) (Result, error) {
+		// Failed probes reopen the circuit, so waits are limited in total
+		var waited time.Duration
+		for {
+			allowed, retryIn := b.allow()
+			if allowed {
+				break
+			}
+
+			if !b.wait || waited+retryIn > b.maxWait {
+				return Result{
+					Value: fmt.Sprintf("Tool '%s' is temporarily unavailable after repeated failures. Try again in %s.", b.name, roundDelay(retryIn)),
+					Type:  ResultTypeRejection,
+				}, nil
+			}
+
+			if err := sleep(ctx, retryIn); err != nil {
+				return Result{}, err
+			}
+			waited += retryIn
+		}
+
+		result, err := next(ctx, call)
+		b.record(result, err)
+
+		return result, err
+	}
+}
+
+// roundDelay rounds a delay up to a whole second, for messages read by the
+// model.
+func roundDelay(delay time.Duration) time.Duration {
+	return max(time.Second, (delay + time.Second - 1).Truncate(time.Second))
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
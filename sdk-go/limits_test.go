@@ -0,0 +1,238 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter, err := newRateLimiter("lookup", RateLimit{Rate: 2, Burst: 2})
+	require.NoError(t, err)
+	limiter.now = clock.Now
+
+	// The burst is available straight away
+	for n := 0; n < 2; n++ {
+		delay, ok := limiter.reserve()
+		assert.True(t, ok)
+		assert.Zero(t, delay)
+	}
+
+	delay, ok := limiter.reserve()
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, delay)
+
+	// Tokens refill at the configured rate
+	clock.Advance(500 * time.Millisecond)
+	_, ok = limiter.reserve()
+	assert.True(t, ok)
+
+	// Waiting calls queue up behind each other, up to MaxWait
+	limiter.wait = true
+	limiter.maxWait = time.Second
+	delay, ok = limiter.reserve()
+	assert.True(t, ok)
+	assert.Equal(t, 500*time.Millisecond, delay)
+	delay, ok = limiter.reserve()
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+	_, ok = limiter.reserve()
+	assert.False(t, ok)
+
+	_, err = newRateLimiter("lookup", RateLimit{})
+	assert.Error(t, err)
+
+	// Waits are limited even without a MaxWait
+	limiter, err = newRateLimiter("lookup", RateLimit{Rate: 0.01, Burst: 1, Wait: true})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRateLimitMaxWait, limiter.maxWait)
+	limiter.now = clock.Now
+	_, ok = limiter.reserve()
+	assert.True(t, ok)
+	delay, ok = limiter.reserve()
+	assert.False(t, ok)
+	assert.Equal(t, 100*time.Second, delay)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	breaker, err := newCircuitBreaker("lookup", CircuitBreaker{Threshold: 2, Cooldown: time.Minute})
+	require.NoError(t, err)
+	breaker.now = clock.Now
+
+	rejection := Result{Type: ResultTypeRejection}
+	resolution := Result{Type: ResultTypeResolution}
+
+	// Successes reset the count of consecutive failures
+	breaker.record(rejection, nil)
+	breaker.record(resolution, nil)
+	breaker.record(rejection, nil)
+	allowed, _ := breaker.allow()
+	assert.True(t, allowed)
+
+	breaker.record(rejection, nil)
+	allowed, retryIn := breaker.allow()
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, retryIn)
+
+	// After the cooldown a single probe is let through
+	clock.Advance(time.Minute)
+	allowed, _ = breaker.allow()
+	assert.True(t, allowed)
+	allowed, _ = breaker.allow()
+	assert.False(t, allowed)
+
+	// A failed probe opens the circuit again
+	breaker.record(rejection, nil)
+	allowed, retryIn = breaker.allow()
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, retryIn)
+
+	// A successful probe closes it
+	clock.Advance(time.Minute)
+	allowed, _ = breaker.allow()
+	assert.True(t, allowed)
+	breaker.record(resolution, nil)
+	allowed, _ = breaker.allow()
+	assert.True(t, allowed)
+
+	_, err = newCircuitBreaker("lookup", CircuitBreaker{Threshold: -1})
+	assert.Error(t, err)
+}
+
+func TestToolLimits(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "https://api.inferable.ai",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	require.NoError(t, i.Tools.Register(Tool{
+		Name:      "limited",
+		Func:      echo,
+		RateLimit: &RateLimit{Rate: 1},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name:      "waiting",
+		Func:      echo,
+		RateLimit: &RateLimit{Rate: 20, Burst: 1, Wait: true},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "fragile",
+		Func: func(input EchoInput, ctx ContextInput) (string, error) {
+			calls.Add(1)
+			return "", fmt.Errorf("downstream unavailable")
+		},
+		CircuitBreaker: &CircuitBreaker{Threshold: 2, Cooldown: time.Hour},
+	}))
+
+	ctx := context.Background()
+	input := json.RawMessage(`{"Input":"hello"}`)
+
+	result, err := i.Tools.Invoke(ctx, "limited", input, ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeResolution, result.Type)
+
+	result, err = i.Tools.Invoke(ctx, "limited", input, ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+	assert.Equal(t, "Tool 'limited' is rate limited. Try again in 1s.", result.Value)
+
+	start := time.Now()
+	for n := 0; n < 3; n++ {
+		result, err = i.Tools.Invoke(ctx, "waiting", input, ContextInput{})
+		require.NoError(t, err)
+		assert.Equal(t, ResultTypeResolution, result.Type)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	for n := 0; n < 3; n++ {
+		result, err = i.Tools.Invoke(ctx, "fragile", input, ContextInput{})
+		require.NoError(t, err)
+		assert.Equal(t, ResultTypeRejection, result.Type)
+	}
+	assert.Equal(t, int64(2), calls.Load())
+	assert.Equal(t, "Tool 'fragile' is temporarily unavailable after repeated failures. Try again in 1h0m0s.", result.Value)
+}
+
+func TestCircuitBreakerWaitIsLimited(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint: "http://localhost",
+		APISecret:   "test-secret",
+		MachineID:   "test-machine",
+	})
+	require.NoError(t, err)
+
+	failing := func(input EchoInput, ctx ContextInput) (string, error) {
+		return "", fmt.Errorf("downstream unavailable")
+	}
+	release := make(chan struct{})
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "probing",
+		Func: func(input EchoInput, ctx ContextInput) (string, error) {
+			if input.Input == "probe" {
+				<-release
+			}
+			return "", fmt.Errorf("downstream unavailable")
+		},
+		CircuitBreaker: &CircuitBreaker{Threshold: 1, Cooldown: 10 * time.Millisecond, Wait: true, MaxWait: 100 * time.Millisecond},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name:           "cooling",
+		Func:           failing,
+		CircuitBreaker: &CircuitBreaker{Threshold: 1, Cooldown: time.Hour, Wait: true},
+	}))
+
+	ctx := context.Background()
+	input := json.RawMessage(`{"Input":"hello"}`)
+
+	// Calls waiting behind a probe that doesn't complete give up after
+	// MaxWait
+	_, err = i.Tools.Invoke(ctx, "probing", input, ContextInput{})
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	probed := make(chan struct{})
+	go func() {
+		defer close(probed)
+		i.Tools.Invoke(ctx, "probing", json.RawMessage(`{"Input":"probe"}`), ContextInput{})
+	}()
+	defer func() {
+		close(release)
+		<-probed
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	result, err := i.Tools.Invoke(ctx, "probing", input, ContextInput{})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+	assert.Contains(t, result.Value, "Tool 'probing' is temporarily unavailable after repeated failures")
+
+	// Calls aren't delayed for cooldowns longer than the default MaxWait
+	_, err = i.Tools.Invoke(ctx, "cooling", input, ContextInput{})
+	require.NoError(t, err)
+	start = time.Now()
+	result, err = i.Tools.Invoke(ctx, "cooling", input, ContextInput{})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "Tool 'cooling' is temporarily unavailable after repeated failures. Try again in 1h0m0s.", result.Value)
+}
@@ -1,6 +1,7 @@
 package inferable
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/inferablehq/inferable/sdk-go/internal/util"
@@ -30,8 +31,9 @@ func TestInferableFunctions(t *testing.T) {
 	machineSecret, _, _, apiEndpoint := util.GetTestVars()
 
 	inferableInstance, err := New(InferableOptions{
-		APIEndpoint: apiEndpoint,
-		APISecret:   machineSecret,
+		APIEndpoint:   apiEndpoint,
+		APISecret:     machineSecret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 	if err != nil {
 		t.Fatalf("Error creating Inferable instance: %v", err)
@@ -112,8 +114,9 @@ func TestInferableFunctions(t *testing.T) {
 		machineSecret, _, _, apiEndpoint := util.GetTestVars()
 
 		instance1, err := New(InferableOptions{
-			APIEndpoint: apiEndpoint,
-			APISecret:   machineSecret,
+			APIEndpoint:   apiEndpoint,
+			APISecret:     machineSecret,
+			MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 		})
 		if err != nil {
 			t.Fatalf("Error creating first Inferable instance: %v", err)
@@ -121,8 +124,9 @@ func TestInferableFunctions(t *testing.T) {
 		id1 := instance1.machineID
 
 		instance2, err := New(InferableOptions{
-			APIEndpoint: apiEndpoint,
-			APISecret:   machineSecret,
+			APIEndpoint:   apiEndpoint,
+			APISecret:     machineSecret,
+			MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 		})
 		if err != nil {
 			t.Fatalf("Error creating second Inferable instance: %v", err)
@@ -0,0 +1,93 @@
+package inferable
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AgentMetrics is a snapshot of an agent's activity since it was created.
+type AgentMetrics struct {
+	// Polls made for jobs, and how many of them failed.
+	Polls        int64
+	PollFailures int64
+	// Jobs handled, by result type.
+	Resolutions int64
+	Rejections  int64
+	Interrupts  int64
+	// Jobs whose result couldn't be persisted.
+	PersistFailures int64
+	// Time of the last successful poll, zero if there hasn't been one.
+	LastPoll time.Time
+}
+
+// Jobs returns the total number of jobs handled.
+func (m AgentMetrics) Jobs() int64 {
+	return m.Resolutions + m.Rejections + m.Interrupts
+}
+
+func (m AgentMetrics) add(other AgentMetrics) AgentMetrics {
+	m.Polls += other.Polls
+	m.PollFailures += other.PollFailures
+	m.Resolutions += other.Resolutions
+	m.Rejections += other.Rejections
+	m.Interrupts += other.Interrupts
+	m.PersistFailures += other.PersistFailures
+	if other.LastPoll.After(m.LastPoll) {
+		m.LastPoll = other.LastPoll
+	}
+	return m
+}
+
+type agentMetrics struct {
+	polls           atomic.Int64
+	pollFailures    atomic.Int64
+	resolutions     atomic.Int64
+	rejections      atomic.Int64
+	interrupts      atomic.Int64
+	persistFailures atomic.Int64
+	// Unix nanoseconds
+	lastPoll atomic.Int64
+}
+
+func (m *agentMetrics) recordPoll(err error) {
+	m.polls.Add(1)
+	if err != nil {
+		m.pollFailures.Add(1)
+		return
+	}
+	m.lastPoll.Store(time.Now().UnixNano())
+}
+
+func (m *agentMetrics) recordJob(resultType ResultType, persistErr error) {
+	switch resultType {
+	case ResultTypeResolution:
+		m.resolutions.Add(1)
+	case ResultTypeRejection:
+		m.rejections.Add(1)
+	case ResultTypeInterrupt:
+		m.interrupts.Add(1)
+	}
+	if persistErr != nil {
+		m.persistFailures.Add(1)
+	}
+}
+
+func (m *agentMetrics) snapshot() AgentMetrics {
+	snapshot := AgentMetrics{
+		Polls:           m.polls.Load(),
+		PollFailures:    m.pollFailures.Load(),
+		Resolutions:     m.resolutions.Load(),
+		Rejections:      m.rejections.Load(),
+		Interrupts:      m.interrupts.Load(),
+		PersistFailures: m.persistFailures.Load(),
+	}
+	if lastPoll := m.lastPoll.Load(); lastPoll != 0 {
+		snapshot.LastPoll = time.Unix(0, lastPoll)
+	}
+	return snapshot
+}
+
+// Metrics returns a snapshot of the agent's polling and job activity.
+func (s *pollingAgent) Metrics() AgentMetrics {
+	return s.metrics.snapshot()
+}
@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"reflect"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/invopop/jsonschema"
 
 	"github.com/inferablehq/inferable/sdk-go/internal/client"
+	"github.com/inferablehq/inferable/sdk-go/internal/validate"
 )
 
 const (
@@ -20,26 +23,102 @@ const (
 	DefaultRetryAfter          = 10
 )
 
+var (
+	// Extra time allowed on top of the poll wait time before a long poll
+	// request is abandoned.
+	pollTimeoutBuffer = 10 * time.Second
+	// Longest the control plane holds a poll open. Polls without a wait time
+	// are held for its default of 15 seconds, within this.
+	serverPollWindow = MaxPollWaitTime * time.Second
+)
+
 type Tool struct {
 	Name        string
 	Description string
 	schema      interface{}
 	Config      interface{}
 	Func        interface{}
+	// Precomputed schemas and a direct call, generated by inferable-gen.
+	// When set, Func is not called through reflection, and is only reflected
+	// on for schemas that include mapped types and registered enums.
+	Generated *GeneratedTool
+	// Reflected from the first return value of Func that is neither an error
+	// nor an Interrupt, and sent to the control plane with the input schema
+	outputSchema interface{}
+	// Check results against the output schema, including those of calls
+	// made with Invoke, rejecting the call if they don't match. Nil slices, maps and pointers
+	// are allowed to encode as null.
+	ValidateOutput  bool
+	outputValidator *validate.Schema
+	// Cache results by input, for tools that are pure lookups
+	Cache *CacheConfig
+	cache *resultCache
+	// Limit how often the tool is called, and stop calling it after
+	// repeated failures
+	RateLimit      *RateLimit
+	rateLimiter    *rateLimiter
+	CircuitBreaker *CircuitBreaker
+	breaker        *circuitBreaker
+	// Limit the size of results, overriding the service's limit
+	ResultLimit *ResultLimit
+	resultLimit ResultLimit
+	// Middleware applied to calls of this tool only, inside any middleware
+	// added to the agent with Use.
+	Middleware []Middleware
+	// Values of the enum types registered when the tool was, checked
+	// against its decoded input
+	enums map[reflect.Type][]interface{}
 }
 
 type ContextInput struct {
 	AuthContext interface{} `json:"authContext,omitempty"`
 	RunContext  interface{} `json:"runContext,omitempty"`
 	Approved    bool        `json:"approved"`
+	// Sends progress reports for the job, nil outside of jobs
+	heartbeat *jobHeartbeat
+	// Enums registered with the tool's agent, for DecodeInput. A pointer so
+	// that context inputs remain comparable.
+	enums *map[reflect.Type][]interface{}
 }
 
 type pollingAgent struct {
-	Tools      map[string]Tool
+	// Tools and middleware registered with the agent, shared with its
+	// mirrors in attached clusters
+	registry *toolRegistry
+	name     string
+	// The number of jobs to fetch and execute at once
+	maxConcurrency int
+	// Seconds to long poll for jobs, or 0 to disable long polling
+	pollWaitTime int
+	// Serializes machine registrations so that the last one to complete
+	// carries the latest tool list
+	registerMu sync.Mutex
 	inferable  *Inferable
-	ctx        context.Context
-	cancel     context.CancelFunc
-	retryAfter int
+	// Cancels the polling loop, nil when not listening. Guarded by stateMu.
+	cancel  context.CancelFunc
+	stateMu sync.Mutex
+	// Seconds to wait before the next poll
+	retryAfter atomic.Int64
+	metrics    agentMetrics
+	// The secret in use when the machine was last registered. Guarded by
+	// registerMu.
+	registeredSecret string
+	// Results of completed jobs, consulted before executing a job
+	idempotencyStore IdempotencyStore
+	// IDs of the jobs currently executing
+	inFlight sync.Map
+	// How often heartbeats are sent for executing jobs, 0 to only send them
+	// when progress is reported
+	heartbeatInterval time.Duration
+	// Set once the control plane has rejected a heartbeat as unsupported
+	heartbeatsUnsupported atomic.Bool
+	// Size limit for results of tools without their own
+	resultLimit ResultLimit
+	// Schemas for types set with MapType and values of enum types set with
+	// RegisterEnum. Guarded by typesMu.
+	types   map[reflect.Type]*jsonschema.Schema
+	enums   map[reflect.Type][]interface{}
+	typesMu sync.Mutex
 }
 
 type callMessage struct {
@@ -53,15 +132,73 @@ type callMessage struct {
 
 type callResultMeta struct {
 	FunctionExecutionTime int64 `json:"functionExecutionTime,omitempty"`
+	CacheHit              bool  `json:"cacheHit,omitempty"`
 }
 
 type callResult struct {
 	Result     interface{}    `json:"result"`
-	ResultType string         `json:"resultType"`
+	ResultType ResultType     `json:"resultType"`
 	Meta       callResultMeta `json:"meta"`
 }
 
-// Registers a Tool against
+// ToolCall describes a call to a tool, as seen by middleware.
+type ToolCall struct {
+	// The ID of the job being handled. Empty for calls made with Invoke.
+	JobID   string
+	Tool    string
+	Input   json.RawMessage
+	Context ContextInput
+}
+
+// ToolHandler handles a call to a tool. Returning an error rejects the call
+// with the error's message.
+type ToolHandler func(ctx context.Context, call ToolCall) (Result, error)
+
+// Middleware wraps a ToolHandler with additional behavior. It may modify the
+// call before passing it on, inspect or replace the result, or return without
+// calling next at all.
+type Middleware func(next ToolHandler) ToolHandler
+
+// ResultType classifies the outcome of a tool call.
+type ResultType string
+
+const (
+	// The tool returned a value.
+	ResultTypeResolution ResultType = "resolution"
+	// The tool returned an error, or couldn't be called with the input.
+	ResultTypeRejection ResultType = "rejection"
+	// The tool returned an Interrupt.
+	ResultTypeInterrupt ResultType = "interrupt"
+)
+
+// Result is the outcome of a tool call.
+type Result struct {
+	// The value returned by the tool, the error message for a rejection or
+	// the Interrupt.
+	Value         interface{}
+	Type          ResultType
+	ExecutionTime time.Duration
+	// Whether the result was served from the tool's cache
+	CacheHit bool
+	// The value with the content of its blobs read, once checked against
+	// the output schema, so that readers aren't read again for the upload
+	encoded interface{}
+}
+
+func (r Result) callResult() callResult {
+	return callResult{
+		Result:     r.Value,
+		ResultType: r.Type,
+		Meta: callResultMeta{
+			FunctionExecutionTime: r.ExecutionTime.Milliseconds(),
+			CacheHit:              r.CacheHit,
+		},
+	}
+}
+
+// Registers a Tool against the agent. Tools may be registered at any time;
+// if the agent is already listening, the machine is re-registered with the
+// control plane and the tool is polled for from the next poll onwards.
 //
 // Parameters:
 // - input: The Tool definition.
@@ -89,92 +226,181 @@ type callResult struct {
 //	// Stop the service on shutdown
 //	defer service.Stop()
 func (s *pollingAgent) Register(fn Tool) error {
-	if s.isPolling() {
-		return fmt.Errorf("tool must be registered before starting the service")
-	}
-
-	if _, exists := s.Tools[fn.Name]; exists {
+	if s.hasTool(fn.Name) {
 		return fmt.Errorf("tool with name '%s' already registered", fn.Name)
 	}
 
-	// Validate that the function has exactly one argument and it's a struct
-	fnType := reflect.TypeOf(fn.Func)
-	if fnType.NumIn() != 2 {
-		return fmt.Errorf("tool '%s' must have exactly two arguments", fn.Name)
+	if owner := s.inferable.toolOwner(fn.Name); owner != nil {
+		return fmt.Errorf("tool with name '%s' already registered with service '%s'", fn.Name, owner.name)
 	}
-	arg1Type := fnType.In(0)
-	arg2Type := fnType.In(1)
 
-	if arg2Type.Kind() != reflect.Struct {
-		return fmt.Errorf("tool '%s' second argument must be a struct (ContextInput)", fn.Name)
+	fn.enums = s.enumMap()
+	types := s.typeMap()
+
+	if fn.Generated != nil {
+		if err := fn.Generated.validate(fn.Name); err != nil {
+			return err
+		}
 	}
 
-	// Set the argument type to the referenced type
-	if arg1Type.Kind() == reflect.Ptr {
-		arg1Type = arg1Type.Elem()
+	// Schemas are generated without the types mapped and enums registered
+	// with the agent, so generated tools are reflected on if there are any
+	if fn.Generated != nil && (fn.Func == nil || (len(types) == 0 && len(fn.enums) == 0)) {
+		fn.schema = fn.Generated.Schema
+		if len(fn.Generated.OutputSchema) > 0 {
+			fn.outputSchema = fn.Generated.OutputSchema
+		}
+	} else {
+		schema, outputSchema, err := reflectTool(fn.Name, fn.Func, schemaOptions{
+			comments: s.inferable.commentMap(),
+			types:    types,
+			enums:    fn.enums,
+		})
+		if err != nil {
+			return err
+		}
+
+		fn.schema = schema
+		if outputSchema != nil {
+			fn.outputSchema = outputSchema
+		}
 	}
 
-	if arg1Type.Kind() != reflect.Struct {
-		return fmt.Errorf("tool '%s' first argument must be a struct or a pointer to a struct", fn.Name)
+	if fn.Description == "" {
+		fn.Description = s.inferable.funcComment(fn.Func)
 	}
 
-	// Get the schema for the input struct
-	reflector := jsonschema.Reflector{DoNotReference: true, Anonymous: true, AllowAdditionalProperties: false}
-	schema := reflector.Reflect(reflect.New(arg1Type).Interface())
+	if fn.ValidateOutput {
+		if fn.outputSchema == nil {
+			return fmt.Errorf("tool '%s' has no output schema to validate against", fn.Name)
+		}
 
-	if schema == nil {
-		return fmt.Errorf("failed to get schema for tool '%s'", fn.Name)
+		validator, err := validate.Compile(fn.outputSchema)
+		if err != nil {
+			return fmt.Errorf("failed to compile output schema for tool '%s': %v", fn.Name, err)
+		}
+		validator.AllowNull = true
+		fn.outputValidator = validator
 	}
 
-	// Extract the relevant part of the schema
-	defs, ok := schema.Definitions[arg1Type.Name()]
-
-	// If the definition is not found, use the whole schema.
-	// This tends to happen for inline structs.
-	// For example: func(input struct { A int `json:"a"` }) int
-	if !ok {
-		defs = schema
+	if fn.Cache != nil {
+		cache, err := newResultCache(fn.Name, *fn.Cache)
+		if err != nil {
+			return err
+		}
+		fn.cache = cache
 	}
 
-	defsString, err := json.Marshal(defs)
+	resultLimit, err := resolveResultLimit(fn.ResultLimit, ResultLimit{})
 	if err != nil {
-		return fmt.Errorf("failed to marshal schema for tool '%s': %v", fn.Name, err)
+		return fmt.Errorf("invalid result limit for tool '%s': %v", fn.Name, err)
+	}
+	fn.resultLimit = resultLimit
+
+	if fn.RateLimit != nil {
+		limiter, err := newRateLimiter(fn.Name, *fn.RateLimit)
+		if err != nil {
+			return err
+		}
+		fn.rateLimiter = limiter
 	}
 
-	if strings.Contains(string(defsString), "\"$ref\":\"#/$defs") {
-		return fmt.Errorf("schema for tool '%s' contains a $ref to an external definition. this is currently not supported. see https://go.inferable.ai/go-schema-limitation for details", fn.Name)
+	if fn.CircuitBreaker != nil {
+		breaker, err := newCircuitBreaker(fn.Name, *fn.CircuitBreaker)
+		if err != nil {
+			return err
+		}
+		fn.breaker = breaker
 	}
 
-	defs.AdditionalProperties = jsonschema.FalseSchema
-	fn.schema = defs
+	return s.registry.add(fn)
+}
 
-	s.Tools[fn.Name] = fn
-	return nil
+// Unregister removes a Tool from the agent. If the agent is listening, the
+// machine is re-registered with the control plane and the tool is no longer
+// polled for. Calls to the tool that are already executing are unaffected.
+func (s *pollingAgent) Unregister(name string) error {
+	return s.registry.remove(name)
+}
+
+func (s *pollingAgent) hasTool(name string) bool {
+	_, exists := s.registry.get(name)
+	return exists
+}
+
+func (s *pollingAgent) getTool(name string) (Tool, bool) {
+	return s.registry.get(name)
+}
+
+// Tools returns a snapshot of the tools registered with the agent, keyed by
+// name. Use Register and Unregister to change them.
+func (s *pollingAgent) Tools() map[string]Tool {
+	return s.registry.snapshot()
+}
+
+// toolList returns a snapshot of the registered tools, sorted by name.
+func (s *pollingAgent) toolList() []Tool {
+	return s.registry.list()
+}
+
+// Use adds middleware that wraps every tool call handled by the agent, for
+// cross-cutting concerns such as logging, auth checks or metrics. Middleware
+// runs in the order it was added, before any middleware set on the Tool.
+//
+// Example:
+//
+//	client.Tools.Use(func(next inferable.ToolHandler) inferable.ToolHandler {
+//	  return func(ctx context.Context, call inferable.ToolCall) (inferable.Result, error) {
+//	    log.Printf("calling %s", call.Tool)
+//	    return next(ctx, call)
+//	  }
+//	})
+func (s *pollingAgent) Use(middleware ...Middleware) {
+	s.registry.use(middleware...)
 }
 
 // Start polling for jobs, registers the machine, and starts polling for messages
 func (s *pollingAgent) Listen() error {
-	_, err := s.inferable.registerMachine(s)
+	if s.isPolling() {
+		return fmt.Errorf("agent is already listening")
+	}
+
+	err := s.register()
 	if err != nil {
 		return fmt.Errorf("failed to register machine: %v", err)
 	}
 
-	s.ctx, s.cancel = context.WithCancel(context.Background())
-	s.retryAfter = 0
+	s.stateMu.Lock()
+	if s.cancel != nil {
+		s.stateMu.Unlock()
+		return fmt.Errorf("agent is already listening")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.stateMu.Unlock()
+
+	s.retryAfter.Store(0)
 
 	go func() {
-		failureCount := DefaultRetryAfter
+		failureCount := 0
 		for {
-			time.Sleep(time.Duration(s.retryAfter) * time.Second)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(s.retryAfter.Load()) * time.Second):
+			}
 
 			select {
-			case <-s.ctx.Done():
+			case <-ctx.Done():
 				return
 			default:
 				err := s.poll()
 
 				if err != nil {
 					failureCount++
+					// Back off so that a failing endpoint isn't hammered
+					// when long polling returns immediately.
+					s.retryAfter.Store(DefaultRetryAfter)
 
 					if failureCount > MaxConsecutivePollFailures {
 						log.Printf("Too many consecutive poll failures, exiting service")
@@ -182,65 +408,134 @@ func (s *pollingAgent) Listen() error {
 					}
 
 					log.Printf("Failed to poll: %v", err)
+				} else {
+					failureCount = 0
 				}
 			}
 		}
 	}()
 
-	log.Printf("started and polling for messages")
+	log.Printf("service '%s' started and polling for messages", s.name)
+	return nil
+}
+
+// register registers the machine and its tools with the control plane,
+// remembering the secret used so that a later rotation can be detected.
+func (s *pollingAgent) register() error {
+	s.registerMu.Lock()
+	defer s.registerMu.Unlock()
+
+	secret, err := s.inferable.currentSecret()
+	if err != nil {
+		return err
+	}
+
+	clusterId, err := s.inferable.registerMachine(s)
+	if err != nil {
+		return err
+	}
+
+	s.registeredSecret = secret
+	if clusterId != "" {
+		s.inferable.setClusterId(clusterId)
+	}
+
 	return nil
 }
 
+// secretRotated reports whether the secret has changed since the machine
+// was last registered.
+func (s *pollingAgent) secretRotated() (bool, error) {
+	secret, err := s.inferable.currentSecret()
+	if err != nil {
+		return false, err
+	}
+
+	s.registerMu.Lock()
+	defer s.registerMu.Unlock()
+
+	return s.registeredSecret != "" && secret != s.registeredSecret, nil
+}
+
 // Stop stops the service and cancels the polling
 func (s *pollingAgent) Unlisten() {
-	if s.cancel != nil {
-		s.cancel()
-		log.Printf("stopped polling for messages")
+	s.stateMu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.stateMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		log.Printf("service '%s' stopped polling for messages", s.name)
 	}
 }
 
-func (s *pollingAgent) poll() error {
-	headers := map[string]string{
-		"Authorization":          "Bearer " + s.inferable.apiSecret,
-		"X-Machine-ID":           s.inferable.machineID,
-		"X-Machine-SDK-Version":  Version,
-		"X-Machine-SDK-Language": "go",
-	}
+func (s *pollingAgent) poll() (err error) {
+	defer func() {
+		s.metrics.recordPoll(err)
+	}()
 
 	clusterId, err := s.inferable.getClusterId()
 	if err != nil {
 		return fmt.Errorf("failed to get cluster id: %v", err)
 	}
 
+	tools := s.toolList()
+	if len(tools) == 0 {
+		// An empty tools filter would match every tool in the cluster
+		s.retryAfter.Store(1)
+		return nil
+	}
+
 	// Build comma-seperated tools list
 	toolList := ""
-	for _, tool := range s.Tools {
+	for _, tool := range tools {
 		toolList = toolList + tool.Name + ","
 	}
-	if len(toolList) > 0 {
-		toolList = toolList[:len(toolList)-1]
+	toolList = toolList[:len(toolList)-1]
+
+	path := fmt.Sprintf("/clusters/%s/jobs?acknowledge=true&tools=%s&status=pending&limit=%d", clusterId, toolList, s.maxConcurrency)
+	waitTime := s.pollWaitTime
+	if waitTime > 0 {
+		path = fmt.Sprintf("%s&waitTime=%d", path, waitTime)
 	}
 
 	options := client.FetchDataOptions{
-		Path:    fmt.Sprintf("/clusters/%s/jobs?acknowledge=true&tools=%s&status=pending&limit=10", clusterId, toolList),
+		Path:    path,
 		Method:  "GET",
-		Headers: headers,
+		Headers: s.inferable.machineHeaders(),
+		Timeout: s.pollTimeout(),
 	}
 
 	result, respHeaders, err, status := s.inferable.fetchData(options)
 
 	if status == 410 {
-		s.inferable.registerMachine(s)
+		s.register()
+	}
+
+	if status == http.StatusUnauthorized {
+		if rotated, _ := s.secretRotated(); rotated {
+			log.Printf("API secret was rotated, re-registering machine")
+			if err := s.register(); err != nil {
+				return fmt.Errorf("failed to re-register machine after secret rotation: %v", err)
+			}
+			// Poll again straight away with the new secret
+			s.retryAfter.Store(0)
+			return nil
+		}
 	}
 
 	if err != nil {
 		return fmt.Errorf("failed to poll jobs: %v", err)
 	}
 
+	// The long poll already waited for jobs, so poll again straight away
+	// unless the control plane asks us to back off.
+	s.retryAfter.Store(0)
 	if retryAfter, ok := respHeaders["Retry-After"]; ok {
 		for _, v := range retryAfter {
 			if i, err := strconv.Atoi(v); err == nil {
-				s.retryAfter = i
+				s.retryAfter.Store(int64(i))
 			}
 		}
 	}
@@ -252,13 +547,24 @@ func (s *pollingAgent) poll() error {
 		return fmt.Errorf("failed to parse poll response: %v", err)
 	}
 
+	// Jobs are executed concurrently, the poll limit bounds how many
+	var wg sync.WaitGroup
+	var errorsMu sync.Mutex
 	errors := []string{}
 	for _, msg := range parsed {
-		err := s.handleMessage(msg)
-		if err != nil {
-			errors = append(errors, err.Error())
-		}
+		wg.Add(1)
+		go func(msg callMessage) {
+			defer wg.Done()
+
+			err := s.handleMessage(msg)
+			if err != nil {
+				errorsMu.Lock()
+				errors = append(errors, err.Error())
+				errorsMu.Unlock()
+			}
+		}(msg)
 	}
+	wg.Wait()
 
 	if len(errors) > 0 {
 		return fmt.Errorf("failed to handle messages: %v", errors)
@@ -269,97 +575,251 @@ func (s *pollingAgent) poll() error {
 
 func (s *pollingAgent) handleMessage(msg callMessage) error {
 	// Find the target function
-	fn, ok := s.Tools[msg.Function]
+	fn, ok := s.getTool(msg.Function)
 	if !ok {
 		log.Printf("Received call for unknown function: %s", msg.Function)
 		return nil
 	}
 
-	// Create a new instance of the function's input type
-	fnType := reflect.TypeOf(fn.Func)
-	argType := fnType.In(0)
-	argPtr := reflect.New(argType)
+	// Skip jobs delivered again while they are still executing
+	if _, executing := s.inFlight.LoadOrStore(msg.Id, struct{}{}); executing {
+		log.Printf("Job %s is already executing, skipping", msg.Id)
+		return nil
+	}
+	defer s.inFlight.Delete(msg.Id)
 
-	inputJson, err := json.Marshal(msg.Input)
+	result, recorded, err := s.idempotencyStore.Load(msg.Id)
+	if err != nil {
+		log.Printf("Failed to load recorded result for job %s: %v", msg.Id, err)
+	}
+	if recorded {
+		log.Printf("Job %s already completed, returning the recorded result", msg.Id)
+		return s.completeJob(msg.Id, result)
+	}
 
+	inputJson, err := json.Marshal(msg.Input)
 	if err != nil {
-		result := callResult{
-			Result:     err.Error(),
-			ResultType: "rejection",
+		result = Result{
+			Value: err.Error(),
+			Type:  ResultTypeRejection,
 		}
-
-		// Persist the job result
-		if err := s.persistJobResult(msg.Id, result); err != nil {
-			return fmt.Errorf("failed to persist job result: %v", err)
+	} else {
+		heartbeat := s.startHeartbeat(msg.Id)
+		result, err = s.handle(context.Background(), fn, ToolCall{
+			JobID: msg.Id,
+			Tool:  fn.Name,
+			Input: inputJson,
+			Context: ContextInput{
+				AuthContext: msg.AuthContext,
+				RunContext:  msg.RunContext,
+				Approved:    msg.Approved,
+				heartbeat:   heartbeat,
+			},
+		})
+		heartbeat.stopHeartbeat()
+		if err != nil {
+			// Keep the structure of a ToolError returned by middleware
+			result = Result{
+				Value: rejectionValue(err),
+				Type:  ResultTypeRejection,
+			}
 		}
 	}
 
-	err = json.Unmarshal(inputJson, argPtr.Interface())
-	if err != nil {
-		result := callResult{
-			Result:     err.Error(),
-			ResultType: "rejection",
+	if result.Type == ResultTypeResolution {
+		value := result.Value
+		if result.encoded != nil {
+			value = result.encoded
+		}
+		value, err := s.uploadBlobs(msg.Id, value)
+		if err != nil {
+			log.Printf("Failed to upload blobs for job %s: %v", msg.Id, err)
+			result = Result{
+				Value:         fmt.Sprintf("tool '%s' returned a blob that could not be uploaded: %v", fn.Name, err),
+				Type:          ResultTypeRejection,
+				ExecutionTime: result.ExecutionTime,
+			}
+		} else {
+			result.Value = value
+			result.encoded = nil
 		}
+	}
 
-		// Persist the job result
-		if err := s.persistJobResult(msg.Id, result); err != nil {
-			return fmt.Errorf("failed to persist job result: %v", err)
+	limit := s.resultLimit
+	if fn.ResultLimit != nil {
+		limit = fn.resultLimit
+	}
+	result = limitResult(fn.Name, result, limit)
+
+	// Record the result before persisting it, so that the tool isn't run
+	// again if persisting fails and the job is redelivered
+	if result.Type != ResultTypeInterrupt {
+		if err := s.idempotencyStore.Store(msg.Id, result); err != nil {
+			log.Printf("Failed to record result for job %s: %v", msg.Id, err)
 		}
 	}
 
-	context := ContextInput{
-		AuthContext: msg.AuthContext,
-		RunContext:  msg.RunContext,
-		Approved:    msg.Approved,
+	return s.completeJob(msg.Id, result)
+}
+
+// completeJob persists the result of a job.
+func (s *pollingAgent) completeJob(jobID string, result Result) error {
+	err := s.persistJobResult(jobID, result.callResult())
+	s.metrics.recordJob(result.Type, err)
+	if err != nil {
+		return fmt.Errorf("failed to persist job result: %v", err)
+	}
+
+	return nil
+}
+
+// Invoke calls a registered tool locally, without contacting the control
+// plane. The input is decoded and the result classified exactly as it would
+// be for a job received while listening, which makes Invoke suitable for
+// unit testing tools or driving them from scripts.
+//
+// An error is returned if the tool isn't registered or middleware returns
+// one. Errors returned by the tool itself are reported as a rejection Result.
+//
+// Example:
+//
+//	result, err := client.Tools.Invoke(ctx, "SayHello", json.RawMessage(`{"input": "world"}`), inferable.ContextInput{})
+func (s *pollingAgent) Invoke(ctx context.Context, name string, input json.RawMessage, contextInput ContextInput) (Result, error) {
+	fn, ok := s.getTool(name)
+	if !ok {
+		return Result{}, fmt.Errorf("tool with name '%s' not found", name)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
 	}
 
-	start := time.Now()
-	// Call the function with the unmarshaled argument
-	fnValue := reflect.ValueOf(fn.Func)
-	returnValues := fnValue.Call([]reflect.Value{argPtr.Elem(), reflect.ValueOf(context)})
-
-	resultType := "resolution"
-	resultValue := returnValues[0].Interface()
-
-	for _, v := range returnValues {
-		// Check if ANY of the return values is an error
-		if v.Type().AssignableTo(reflect.TypeOf((*error)(nil)).Elem()) && v.Interface() != nil {
-			resultType = "rejection"
-			// Serialize the error
-			resultValue = v.Interface().(error).Error()
-			break
+	return s.handle(ctx, fn, ToolCall{
+		Tool:    fn.Name,
+		Input:   input,
+		Context: contextInput,
+	})
+}
+
+// handle runs a call through the agent and tool middleware before executing
+// the tool, then checks the result against the tool's output schema.
+func (s *pollingAgent) handle(ctx context.Context, fn Tool, call ToolCall) (Result, error) {
+	handler := ToolHandler(func(ctx context.Context, call ToolCall) (Result, error) {
+		return s.execute(ctx, fn, call.Input, call.Context), nil
+	})
+
+	// The circuit breaker sees only calls the rate limit lets through, and
+	// neither applies to cached results
+	if fn.breaker != nil {
+		handler = fn.breaker.middleware(handler)
+	}
+	if fn.rateLimiter != nil {
+		handler = fn.rateLimiter.middleware(handler)
+	}
+
+	// Cached results are served inside all middleware, so that checks such
+	// as authorization still apply to them
+	if fn.cache != nil {
+		handler = fn.cache.middleware(handler)
+	}
+
+	// Apply in reverse so that the first middleware is the outermost
+	for i := len(fn.Middleware) - 1; i >= 0; i-- {
+		handler = fn.Middleware[i](handler)
+	}
+
+	middleware := s.registry.middlewareList()
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	result, err := handler(ctx, call)
+	if err != nil {
+		return result, err
+	}
+
+	return validateOutput(fn, result), nil
+}
+
+// validateOutput rejects a resolution that doesn't match the tool's output
+// schema, if it has one. Blobs are checked as the references they are
+// uploaded as. The result's value is left as returned, with the content of
+// its blobs kept aside for the upload.
+func validateOutput(fn Tool, result Result) Result {
+	if fn.outputValidator == nil || result.Type != ResultTypeResolution {
+		return result
+	}
+
+	value := result.Value
+	if containsBlob(reflect.ValueOf(value), 0) {
+		// Blobs that can't be encoded reject the job when they are uploaded
+		encoded, err := encodeBlobs(value)
+		if err != nil {
+			return result
 		}
+		result.encoded, value = encoded, encoded
+	}
 
-		// Check if ANY of the return values is an interrupt
-		if v.CanInterface() {
-			val := v.Interface()
-			switch t := val.(type) {
-			case Interrupt:
-				resultType = "interrupt"
-				resultValue = t
-			case *Interrupt:
-				if t != nil {
-					resultType = "interrupt"
-					resultValue = *t
-				}
-			}
+	reference, err := replaceBlobs(value, func(blob blobData) (interface{}, error) {
+		return blobReference{Name: blob.Name, Type: blob.Type, Size: blob.Size}, nil
+	})
+	if err == nil {
+		err = fn.outputValidator.Validate(reference)
+	}
+	if err != nil {
+		log.Printf("Tool '%s' returned an invalid result: %v", fn.Name, err)
+		return Result{
+			Value:         fmt.Sprintf("tool '%s' returned a result that does not match its output schema: %v", fn.Name, err),
+			Type:          ResultTypeRejection,
+			ExecutionTime: result.ExecutionTime,
+			CacheHit:      result.CacheHit,
 		}
 	}
 
-	result := callResult{
-		Result:     resultValue,
-		ResultType: resultType,
-		Meta: callResultMeta{
-			FunctionExecutionTime: int64(time.Since(start).Milliseconds()),
-		},
+	return result
+}
+
+// execute decodes the input, calls the tool and classifies its return values.
+func (s *pollingAgent) execute(ctx context.Context, fn Tool, input json.RawMessage, contextInput ContextInput) Result {
+	if len(input) == 0 {
+		input = json.RawMessage("null")
 	}
 
-	// Persist the job result
-	if err := s.persistJobResult(msg.Id, result); err != nil {
-		return fmt.Errorf("failed to persist job result: %v", err)
+	if fn.Generated != nil {
+		contextInput.enums = &fn.enums
+		start := time.Now()
+		result := fn.Generated.Call(input, contextInput)
+		result.ExecutionTime = time.Since(start)
+		return result
 	}
 
-	return nil
+	// Create a new instance of the function's input type
+	fnType := reflect.TypeOf(fn.Func)
+	argType := fnType.In(0)
+	argPtr := reflect.New(argType)
+
+	err := decodeInput(input, argPtr.Interface(), fn.enums)
+	if err != nil {
+		return Result{
+			Value: err.Error(),
+			Type:  ResultTypeRejection,
+		}
+	}
+
+	start := time.Now()
+	// Call the function with the unmarshaled argument
+	fnValue := reflect.ValueOf(fn.Func)
+	returnValues := fnValue.Call([]reflect.Value{argPtr.Elem(), reflect.ValueOf(contextInput)})
+
+	values := make([]interface{}, len(returnValues))
+	for i, v := range returnValues {
+		values[i] = v.Interface()
+	}
+
+	result := NewResult(values...)
+	result.ExecutionTime = time.Since(start)
+
+	return result
 }
 
 func (s *pollingAgent) persistJobResult(jobID string, result callResult) error {
@@ -368,13 +828,6 @@ func (s *pollingAgent) persistJobResult(jobID string, result callResult) error {
 		return fmt.Errorf("failed to marshal payload for persistJobResult: %v", err)
 	}
 
-	headers := map[string]string{
-		"Authorization":          "Bearer " + s.inferable.apiSecret,
-		"X-Machine-ID":           s.inferable.machineID,
-		"X-Machine-SDK-Version":  Version,
-		"X-Machine-SDK-Language": "go",
-	}
-
 	clusterId, err := s.inferable.getClusterId()
 	if err != nil {
 		return fmt.Errorf("failed to get cluster id: %v", err)
@@ -383,7 +836,7 @@ func (s *pollingAgent) persistJobResult(jobID string, result callResult) error {
 	options := client.FetchDataOptions{
 		Path:    fmt.Sprintf("/clusters/%s/jobs/%s/result", clusterId, jobID),
 		Method:  "POST",
-		Headers: headers,
+		Headers: s.inferable.machineHeaders(),
 		Body:    string(payloadJSON),
 	}
 
@@ -395,23 +848,41 @@ func (s *pollingAgent) persistJobResult(jobID string, result callResult) error {
 	return nil
 }
 
+// pollTimeout returns how long a poll may take before it is abandoned. The
+// control plane long polls even without a wait time, so polls with long
+// polling disabled are allowed as long as its own timeout.
+func (s *pollingAgent) pollTimeout() time.Duration {
+	if s.pollWaitTime <= 0 {
+		return serverPollWindow + pollTimeoutBuffer
+	}
+	return time.Duration(s.pollWaitTime)*time.Second + pollTimeoutBuffer
+}
+
 func (s *pollingAgent) getSchema() (map[string]interface{}, error) {
-	if len(s.Tools) == 0 {
+	tools := s.toolList()
+	if len(tools) == 0 {
 		return nil, fmt.Errorf("no tools registered")
 	}
 
 	schema := make(map[string]interface{})
 
-	for _, fn := range s.Tools {
-		schema[fn.Name] = map[string]interface{}{
+	for _, fn := range tools {
+		entry := map[string]interface{}{
 			"input": fn.schema,
 			"name":  fn.Name,
 		}
+		if fn.outputSchema != nil {
+			entry["output"] = fn.outputSchema
+		}
+		schema[fn.Name] = entry
 	}
 
 	return schema, nil
 }
 
 func (s *pollingAgent) isPolling() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
 	return s.cancel != nil
 }
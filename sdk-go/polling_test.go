@@ -0,0 +1,600 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestPollUsesLongPolling(t *testing.T) {
+	queries := make(chan url.Values, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		case "/clusters/test-cluster/jobs":
+			select {
+			case queries <- r.URL.Query():
+			default:
+			}
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		pollWaitTime int
+		expected     string
+	}{
+		{"default", 0, "20"},
+		{"custom", 5, "5"},
+		{"disabled", -1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := New(InferableOptions{
+				APIEndpoint:   server.URL,
+				APISecret:     "test-secret",
+				PollWaitTime:  tt.pollWaitTime,
+				MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+			})
+			require.NoError(t, err)
+			require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+
+			require.NoError(t, i.Tools.poll())
+
+			select {
+			case q := <-queries:
+				assert.Equal(t, tt.expected, q.Get("waitTime"))
+				assert.Equal(t, "true", q.Get("acknowledge"))
+			case <-time.After(time.Second):
+				t.Fatal("expected a poll request")
+			}
+			assert.Equal(t, int64(0), i.Tools.retryAfter.Load())
+		})
+	}
+}
+
+func TestPollWaitsForJobs(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+		PollWaitTime:  5,
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.register())
+
+	// A job enqueued while the poll is open is returned by that poll
+	polled := make(chan error, 1)
+	go func() {
+		polled <- i.Tools.poll()
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	jobID := server.Enqueue(t, "echo", EchoInput{Input: "waited"})
+
+	select {
+	case err := <-polled:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the poll to return the job")
+	}
+
+	result := server.WaitForResult(t, jobID)
+	assert.Equal(t, "resolution", result.ResultType)
+}
+
+func TestPollHonorsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		case "/clusters/test-cluster/jobs":
+			w.Header().Set("Retry-After", "3")
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+
+	require.NoError(t, i.Tools.poll())
+	assert.Equal(t, int64(3), i.Tools.retryAfter.Load())
+}
+
+func TestPollWithoutWaitTimeAllowsServerLongPoll(t *testing.T) {
+	defer func(buffer, window time.Duration) {
+		pollTimeoutBuffer, serverPollWindow = buffer, window
+	}(pollTimeoutBuffer, serverPollWindow)
+	pollTimeoutBuffer, serverPollWindow = 100*time.Millisecond, 300*time.Millisecond
+
+	// The control plane long polls with its own timeout when no wait time is
+	// given, which may be longer than the buffer alone
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		case "/clusters/test-cluster/jobs":
+			time.Sleep(250 * time.Millisecond)
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		PollWaitTime:  -1,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+
+	require.NoError(t, i.Tools.poll())
+}
+
+func TestNewRejectsExcessivePollWaitTime(t *testing.T) {
+	_, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		PollWaitTime:  MaxPollWaitTime + 1,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	assert.Error(t, err)
+}
+
+func TestInvoke(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   DefaultAPIEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "add",
+		Func: func(input TestInput, ctx ContextInput) int { return input.A + input.B },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "fail",
+		Func: func(input TestInput, ctx ContextInput) (*int, error) { return nil, fmt.Errorf("test error") },
+	}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "approve",
+		Func: func(input TestInput, ctx ContextInput) (*int, *Interrupt) {
+			if !ctx.Approved {
+				return nil, ApprovalInterrupt()
+			}
+			return &input.A, nil
+		},
+	}))
+
+	ctx := context.Background()
+
+	result, err := i.Tools.Invoke(ctx, "add", json.RawMessage(`{"a": 2, "b": 3}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeResolution, result.Type)
+	assert.Equal(t, 5, result.Value)
+
+	result, err = i.Tools.Invoke(ctx, "fail", json.RawMessage(`{}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+	assert.Equal(t, "test error", result.Value)
+
+	result, err = i.Tools.Invoke(ctx, "approve", json.RawMessage(`{"a": 1}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeInterrupt, result.Type)
+	assert.Equal(t, Interrupt{Type: APPROVAL}, result.Value)
+
+	result, err = i.Tools.Invoke(ctx, "approve", json.RawMessage(`{"a": 1}`), ContextInput{Approved: true})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeResolution, result.Type)
+
+	// Input that can't be decoded is rejected without calling the tool
+	result, err = i.Tools.Invoke(ctx, "add", json.RawMessage(`{"a": "two"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+
+	_, err = i.Tools.Invoke(ctx, "missing", nil, ContextInput{})
+	assert.Error(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = i.Tools.Invoke(cancelled, "add", json.RawMessage(`{}`), ContextInput{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHandleMessageRejectsInvalidInput(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	called := false
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "echo",
+		Func: func(input EchoInput, ctx ContextInput) string {
+			called = true
+			return input.Input
+		},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	jobID := server.Enqueue(t, "echo", map[string]int{"Input": 1})
+	require.NoError(t, i.Tools.poll())
+
+	result, ok := server.Result(jobID)
+	require.True(t, ok)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.False(t, called)
+}
+
+func TestOutputSchema(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	type Order struct {
+		ID    string   `json:"id"`
+		Items []string `json:"items"`
+		Total float64  `json:"total,omitempty"`
+	}
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "getOrder",
+		Func: func(input EchoInput, ctx ContextInput) (*Order, *Interrupt, error) {
+			return &Order{ID: input.Input}, nil, nil
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "untyped",
+		Func: func(input EchoInput, ctx ContextInput) (interface{}, error) { return nil, nil },
+	}))
+	require.NoError(t, i.Tools.register())
+
+	tool, ok := server.Tool("getOrder")
+	require.True(t, ok)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(tool.OutputSchema), &schema))
+	assert.Equal(t, "object", schema["type"])
+	assert.ElementsMatch(t, []interface{}{"id", "items"}, schema["required"])
+	assert.Contains(t, schema["properties"], "total")
+
+	tool, _ = server.Tool("echo")
+	assert.JSONEq(t, `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"string"}`, tool.OutputSchema)
+
+	tool, _ = server.Tool("untyped")
+	assert.Empty(t, tool.OutputSchema)
+
+	assert.Error(t, i.Tools.Register(Tool{
+		Name:           "untypedValidated",
+		Func:           func(input EchoInput, ctx ContextInput) interface{} { return nil },
+		ValidateOutput: true,
+	}), "there is no output schema to validate against")
+}
+
+func TestValidateOutput(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	type Status struct {
+		State string   `json:"state" jsonschema:"enum=open,enum=closed"`
+		Tags  []string `json:"tags"`
+	}
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "status",
+		Func: func(input EchoInput, ctx ContextInput) (Status, error) {
+			if input.Input == "fail" {
+				return Status{}, fmt.Errorf("lookup failed")
+			}
+			return Status{State: input.Input}, nil
+		},
+		ValidateOutput: true,
+	}))
+	require.NoError(t, i.Tools.register())
+
+	valid := server.Enqueue(t, "status", EchoInput{Input: "open"})
+	invalid := server.Enqueue(t, "status", EchoInput{Input: "pending"})
+	failed := server.Enqueue(t, "status", EchoInput{Input: "fail"})
+	require.NoError(t, i.Tools.poll())
+
+	// Nil slices encode as null and are accepted
+	result, ok := server.Result(valid)
+	require.True(t, ok)
+	assert.Equal(t, "resolution", result.ResultType)
+	assert.JSONEq(t, `{"state":"open","tags":null}`, string(result.Result))
+
+	result, _ = server.Result(invalid)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Contains(t, string(result.Result), "does not match its output schema")
+	assert.Contains(t, string(result.Result), "$.state")
+
+	// Rejections are passed through unchanged
+	result, _ = server.Result(failed)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.JSONEq(t, `"lookup failed"`, string(result.Result))
+
+	// Local calls are validated too, including results replaced by middleware
+	invoked, err := i.Tools.Invoke(context.Background(), "status", json.RawMessage(`{"Input": "pending"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, invoked.Type)
+	assert.Contains(t, invoked.Value, "does not match its output schema")
+
+	i.Tools.Use(func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call ToolCall) (Result, error) {
+			return Result{Value: Status{State: "unknown"}, Type: ResultTypeResolution}, nil
+		}
+	})
+	invoked, err = i.Tools.Invoke(context.Background(), "status", json.RawMessage(`{"Input": "open"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, invoked.Type)
+	assert.Contains(t, invoked.Value, "$.state")
+}
+
+func TestMiddleware(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(next ToolHandler) ToolHandler {
+			return func(ctx context.Context, call ToolCall) (Result, error) {
+				calls = append(calls, name)
+				return next(ctx, call)
+			}
+		}
+	}
+
+	i.Tools.Use(record("agent-1"), record("agent-2"))
+	i.Tools.Use(func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call ToolCall) (Result, error) {
+			if call.Context.AuthContext == nil {
+				return Result{}, fmt.Errorf("unauthorized")
+			}
+			return next(ctx, call)
+		}
+	})
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "echo",
+		Func: func(input EchoInput, ctx ContextInput) string { return input.Input },
+		Middleware: []Middleware{
+			record("tool"),
+			// Redact the input before it reaches the tool
+			func(next ToolHandler) ToolHandler {
+				return func(ctx context.Context, call ToolCall) (Result, error) {
+					call.Input = json.RawMessage(`{"Input": "[redacted]"}`)
+					return next(ctx, call)
+				}
+			},
+		},
+	}))
+
+	result, err := i.Tools.Invoke(context.Background(), "echo", json.RawMessage(`{"Input": "secret"}`), ContextInput{AuthContext: "user"})
+	require.NoError(t, err)
+	assert.Equal(t, "[redacted]", result.Value)
+	assert.Equal(t, []string{"agent-1", "agent-2", "tool"}, calls)
+
+	// Middleware errors are returned from Invoke and persisted as rejections
+	_, err = i.Tools.Invoke(context.Background(), "echo", json.RawMessage(`{}`), ContextInput{})
+	assert.EqualError(t, err, "unauthorized")
+
+	require.NoError(t, i.Tools.register())
+	jobID, err := server.EnqueueJob(inferabletest.JobInput{Tool: "echo", Input: EchoInput{Input: "secret"}})
+	require.NoError(t, err)
+	require.NoError(t, i.Tools.poll())
+
+	jobResult, ok := server.Result(jobID)
+	require.True(t, ok)
+	assert.Equal(t, "rejection", jobResult.ResultType)
+	assert.JSONEq(t, `"unauthorized"`, string(jobResult.Result))
+}
+
+func TestRegisterWhileListening(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:  server.URL,
+		APISecret:    server.Secret,
+		MachineID:    "dynamic-machine",
+		PollWaitTime: 1,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "echo",
+		Func: func(input EchoInput, ctx ContextInput) string { return input.Input },
+	}))
+	require.NoError(t, i.Tools.Listen())
+	defer i.Tools.Unlisten()
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "reverse",
+		Func: reverse,
+	}))
+	assert.Equal(t, 2, server.Registrations("dynamic-machine"))
+	_, ok := server.Tool("reverse")
+	assert.True(t, ok)
+
+	result := server.WaitForResult(t, server.Enqueue(t, "reverse", ReverseInput{Input: "abc"}))
+	assert.JSONEq(t, `"cba"`, string(result.Result))
+
+	require.NoError(t, i.Tools.Unregister("echo"))
+	assert.Equal(t, 3, server.Registrations("dynamic-machine"))
+	assert.Error(t, i.Tools.Unregister("echo"))
+	assert.NotContains(t, i.Tools.Tools(), "echo")
+	assert.Contains(t, i.Tools.Tools(), "reverse")
+
+	// Jobs for the removed tool are no longer picked up
+	jobID := server.Enqueue(t, "echo", EchoInput{Input: "hello"})
+	_, err = server.AwaitResult(jobID, 2500*time.Millisecond)
+	assert.Error(t, err)
+	job, _ := server.Job(jobID)
+	assert.Equal(t, inferabletest.StatusPending, job.Status)
+}
+
+func TestRegisterWhileListeningRollsBackOnFailure(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		PollWaitTime:  1,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.Listen())
+	defer i.Tools.Unlisten()
+
+	server.SetSecret("sk_rotated")
+
+	assert.Error(t, i.Tools.Register(Tool{Name: "reverse", Func: reverse}))
+	_, ok := i.Tools.getTool("reverse")
+	assert.False(t, ok)
+}
+
+func TestPollSkippedWithoutTools(t *testing.T) {
+	polled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machines":
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		default:
+			polled = true
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.poll())
+	assert.False(t, polled)
+}
+
+func TestConcurrentAgentAccess(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		PollWaitTime:  1,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.Listen())
+	defer i.Tools.Unlisten()
+
+	var wg sync.WaitGroup
+	run := func(fn func(n int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				fn(n)
+			}
+		}()
+	}
+
+	run(func(n int) {
+		name := fmt.Sprintf("reverse%d", n)
+		assert.NoError(t, i.Tools.Register(Tool{Name: name, Func: reverse}))
+		assert.NoError(t, i.Tools.Unregister(name))
+	})
+	run(func(n int) {
+		i.Tools.Unlisten()
+		i.Tools.Listen()
+	})
+	run(func(n int) {
+		// Keep the long polls below short by making sure there are jobs
+		server.Enqueue(t, "echo", EchoInput{Input: "hello"})
+		i.Tools.poll()
+	})
+	run(func(n int) {
+		server.Enqueue(t, "echo", EchoInput{Input: "hello"})
+		_, err := i.Tools.Invoke(context.Background(), "echo", json.RawMessage(`{"Input": "hi"}`), ContextInput{})
+		assert.NoError(t, err)
+	})
+	run(func(n int) {
+		i.Tools.Use(func(next ToolHandler) ToolHandler { return next })
+		_, err := i.Tools.getSchema()
+		assert.NoError(t, err)
+	})
+	run(func(n int) {
+		tools := i.Tools.Tools()
+		assert.Contains(t, tools, "echo")
+		delete(tools, "echo")
+	})
+	run(func(n int) {
+		_, err := i.getClusterId()
+		assert.NoError(t, err)
+	})
+
+	wg.Wait()
+
+	i.Tools.Unlisten()
+	require.NoError(t, i.Tools.Listen())
+	result := server.WaitForResult(t, server.Enqueue(t, "echo", EchoInput{Input: "done"}))
+	assert.JSONEq(t, `"done"`, string(result.Result))
+}
@@ -0,0 +1,161 @@
+package inferable
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// toolRegistry holds the tools and middleware of a service. It is shared by
+// every agent polling for the service, one per attached cluster.
+type toolRegistry struct {
+	mu         sync.RWMutex
+	tools      map[string]Tool
+	middleware []Middleware
+	// Agents polling for these tools, re-registered when the tools change
+	agents []*pollingAgent
+}
+
+func newToolRegistry() *toolRegistry {
+	return &toolRegistry{
+		tools: make(map[string]Tool),
+	}
+}
+
+func (r *toolRegistry) attach(agent *pollingAgent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.agents = append(r.agents, agent)
+}
+
+func (r *toolRegistry) listeningAgents() []*pollingAgent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agents := []*pollingAgent{}
+	for _, agent := range r.agents {
+		if agent.isPolling() {
+			agents = append(agents, agent)
+		}
+	}
+
+	return agents
+}
+
+// add registers a tool, then re-registers every listening agent with its
+// cluster. The tool is removed again if any cluster rejects it.
+func (r *toolRegistry) add(fn Tool) error {
+	r.mu.Lock()
+	if _, exists := r.tools[fn.Name]; exists {
+		r.mu.Unlock()
+		return fmt.Errorf("tool with name '%s' already registered", fn.Name)
+	}
+	r.tools[fn.Name] = fn
+	r.mu.Unlock()
+
+	if err := r.registerAgents(); err != nil {
+		r.mu.Lock()
+		delete(r.tools, fn.Name)
+		r.mu.Unlock()
+		r.registerAgents()
+		return fmt.Errorf("failed to register tool '%s' with the control plane: %v", fn.Name, err)
+	}
+
+	return nil
+}
+
+// remove unregisters a tool, then re-registers every listening agent with its
+// cluster. The tool is restored if any cluster rejects the change.
+func (r *toolRegistry) remove(name string) error {
+	r.mu.Lock()
+	fn, exists := r.tools[name]
+	if !exists {
+		r.mu.Unlock()
+		return fmt.Errorf("tool with name '%s' not registered", name)
+	}
+	delete(r.tools, name)
+	empty := len(r.tools) == 0
+	r.mu.Unlock()
+
+	// A machine can't be registered without tools, the next poll is skipped
+	// instead.
+	if empty {
+		return nil
+	}
+
+	if err := r.registerAgents(); err != nil {
+		r.mu.Lock()
+		r.tools[name] = fn
+		r.mu.Unlock()
+		r.registerAgents()
+		return fmt.Errorf("failed to unregister tool '%s' with the control plane: %v", name, err)
+	}
+
+	return nil
+}
+
+func (r *toolRegistry) registerAgents() error {
+	errors := []string{}
+	for _, agent := range r.listeningAgents() {
+		if err := agent.register(); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
+func (r *toolRegistry) get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, exists := r.tools[name]
+	return fn, exists
+}
+
+// snapshot returns a copy of the registered tools, keyed by name.
+func (r *toolRegistry) snapshot() map[string]Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make(map[string]Tool, len(r.tools))
+	for name, fn := range r.tools {
+		tools[name] = fn
+	}
+
+	return tools
+}
+
+// list returns a snapshot of the registered tools, sorted by name.
+func (r *toolRegistry) list() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.tools))
+	for _, fn := range r.tools {
+		tools = append(tools, fn)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	return tools
+}
+
+func (r *toolRegistry) use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, middleware...)
+}
+
+func (r *toolRegistry) middlewareList() []Middleware {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.middleware
+}
@@ -0,0 +1,225 @@
+package inferable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"unicode/utf8"
+)
+
+// The largest request body the control plane accepts.
+const maxRequestBytes = 1 << 20
+
+// DefaultMaxResultBytes is the largest result sent to the control plane
+// unless configured otherwise, matching the control plane's request limit.
+const DefaultMaxResultBytes = maxRequestBytes
+
+// ResultLimitPolicy is what happens to a result over its size limit.
+type ResultLimitPolicy string
+
+const (
+	// ResultLimitReject rejects the job, explaining that the result was too
+	// large.
+	ResultLimitReject ResultLimitPolicy = "reject"
+	// ResultLimitTruncate cuts the ends off the longest strings and arrays,
+	// marking what was removed.
+	ResultLimitTruncate ResultLimitPolicy = "truncate"
+	// ResultLimitSummarize keeps the start and end of the longest strings
+	// and arrays, marking what was removed from the middle.
+	ResultLimitSummarize ResultLimitPolicy = "summarize"
+)
+
+// ResultLimit limits the size of a tool's results, as encoded in JSON and
+// sent to the control plane along with their type and metadata.
+type ResultLimit struct {
+	// MaxBytes is the largest result allowed. Zero uses
+	// DefaultMaxResultBytes, a negative value disables the limit.
+	MaxBytes int
+	// Policy for results over the limit. Defaults to ResultLimitReject.
+	Policy ResultLimitPolicy
+}
+
+func (l ResultLimit) validate() error {
+	switch l.Policy {
+	case "", ResultLimitReject, ResultLimitTruncate, ResultLimitSummarize:
+		return nil
+	default:
+		return fmt.Errorf("unknown result limit policy '%s'", l.Policy)
+	}
+}
+
+// resolveResultLimit returns the limit with defaults applied, or the
+// fallback if there is no limit.
+func resolveResultLimit(limit *ResultLimit, fallback ResultLimit) (ResultLimit, error) {
+	if limit == nil {
+		return fallback, nil
+	}
+
+	if err := limit.validate(); err != nil {
+		return ResultLimit{}, err
+	}
+
+	resolved := *limit
+	if resolved.MaxBytes == 0 {
+		resolved.MaxBytes = DefaultMaxResultBytes
+	}
+	if resolved.Policy == "" {
+		resolved.Policy = ResultLimitReject
+	}
+
+	return resolved, nil
+}
+
+// limitResult applies a size limit to a result, returning it unchanged if
+// it is within the limit. Results are measured as sent to the control
+// plane, with their type and metadata.
+func limitResult(toolName string, result Result, limit ResultLimit) Result {
+	if limit.MaxBytes <= 0 || result.Type == ResultTypeInterrupt {
+		return result
+	}
+
+	body, err := json.Marshal(result.callResult())
+	if err != nil || len(body) <= limit.MaxBytes {
+		// Values that can't be encoded fail when the result is persisted
+		return result
+	}
+
+	if limit.Policy == ResultLimitTruncate || limit.Policy == ResultLimitSummarize {
+		encoded, err := json.Marshal(result.Value)
+		if err != nil {
+			return result
+		}
+
+		// Leave room for everything sent alongside the value
+		valueLimit := limit
+		valueLimit.MaxBytes -= len(body) - len(encoded)
+		if value, ok := shrinkToFit(encoded, valueLimit); ok {
+			log.Printf("Tool '%s' returned a result of %d bytes, over the limit of %d bytes, and it was shortened", toolName, len(body), limit.MaxBytes)
+			result.Value = value
+			return result
+		}
+	}
+
+	log.Printf("Tool '%s' returned a result of %d bytes, over the limit of %d bytes", toolName, len(body), limit.MaxBytes)
+	return Result{
+		Value:         fmt.Sprintf("tool '%s' returned a result of %d bytes, which is over the limit of %d bytes. Request less data, for example by filtering or paginating.", toolName, len(body), limit.MaxBytes),
+		Type:          ResultTypeRejection,
+		ExecutionTime: result.ExecutionTime,
+	}
+}
+
+// shrinkToFit shortens the strings and arrays in an encoded value until it
+// fits the limit. It finds the most items and characters that can be kept
+// from each, so that short ones are left intact.
+func shrinkToFit(encoded []byte, limit ResultLimit) (interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	fits := func(keep int) (interface{}, bool) {
+		shrunk := shrinkValue(value, keep, limit.Policy == ResultLimitSummarize)
+		data, err := json.Marshal(shrunk)
+		return shrunk, err == nil && len(data) <= limit.MaxBytes
+	}
+
+	best, ok := fits(0)
+	if !ok {
+		return nil, false
+	}
+
+	low, high := 0, longest(value)
+	for low < high {
+		mid := low + (high-low+1)/2
+		if shrunk, ok := fits(mid); ok {
+			best, low = shrunk, mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return best, true
+}
+
+// shrinkValue copies a decoded JSON value, keeping at most keep items of
+// each array and characters of each string. Summarizing keeps the start and
+// end rather than only the start.
+func shrinkValue(value interface{}, keep int, summarize bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		marker := fmt.Sprintf("...[%d characters truncated]...", length-keep)
+		// Strings no longer than the marker would only grow
+		if length-keep <= len(marker) {
+			return v
+		}
+		runes := []rune(v)
+		head, tail := headTail(keep, summarize)
+		return string(runes[:head]) + marker + string(runes[len(runes)-tail:])
+	case []interface{}:
+		head, tail := len(v), 0
+		marker := ""
+		if len(v) > keep {
+			head, tail = headTail(keep, summarize)
+			marker = fmt.Sprintf("...[%d items truncated]...", len(v)-keep)
+			// Keep items that take up no more space than the marker
+			if removed, err := json.Marshal(v[head : len(v)-tail]); err != nil || len(removed) <= len(marker)+2 {
+				head, tail, marker = len(v), 0, ""
+			}
+		}
+		shrunk := make([]interface{}, 0, head+tail+1)
+		for _, item := range v[:head] {
+			shrunk = append(shrunk, shrinkValue(item, keep, summarize))
+		}
+		if marker != "" {
+			shrunk = append(shrunk, marker)
+		}
+		for _, item := range v[len(v)-tail:] {
+			shrunk = append(shrunk, shrinkValue(item, keep, summarize))
+		}
+		return shrunk
+	case map[string]interface{}:
+		shrunk := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			shrunk[key] = shrinkValue(item, keep, summarize)
+		}
+		return shrunk
+	default:
+		return value
+	}
+}
+
+// headTail divides the number of items to keep between the start and the end.
+func headTail(keep int, summarize bool) (int, int) {
+	if !summarize {
+		return keep, 0
+	}
+	return keep - keep/2, keep / 2
+}
+
+// longest returns the length of the longest string or array in a decoded
+// JSON value.
+func longest(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return utf8.RuneCountInString(v)
+	case []interface{}:
+		n := len(v)
+		for _, item := range v {
+			n = max(n, longest(item))
+		}
+		return n
+	case map[string]interface{}:
+		n := 0
+		for _, item := range v {
+			n = max(n, longest(item))
+		}
+		return n
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,145 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestLimitResult(t *testing.T) {
+	rows := make([]int, 50)
+	for n := range rows {
+		rows[n] = n + 1
+	}
+	value := map[string]interface{}{
+		"status": "ok",
+		"rows":   rows,
+		"log":    strings.Repeat("a", 100) + strings.Repeat("z", 100),
+	}
+	result := Result{Value: value, Type: ResultTypeResolution}
+
+	encode := func(result Result) string {
+		data, err := json.Marshal(result.Value)
+		require.NoError(t, err)
+		return string(data)
+	}
+	// Results are measured with everything sent alongside them
+	body := func(result Result) int {
+		data, err := json.Marshal(result.callResult())
+		require.NoError(t, err)
+		return len(data)
+	}
+	size := body(result)
+	assert.Greater(t, size, len(encode(result)))
+
+	// Within the limit
+	assert.Equal(t, result, limitResult("query", result, ResultLimit{MaxBytes: size, Policy: ResultLimitReject}))
+
+	rejected := limitResult("query", result, ResultLimit{MaxBytes: 200, Policy: ResultLimitReject})
+	assert.Equal(t, ResultTypeRejection, rejected.Type)
+	assert.Contains(t, rejected.Value, fmt.Sprintf("tool 'query' returned a result of %d bytes, which is over the limit of 200 bytes", size))
+
+	truncated := limitResult("query", result, ResultLimit{MaxBytes: 200, Policy: ResultLimitTruncate})
+	assert.Equal(t, ResultTypeResolution, truncated.Type)
+	assert.LessOrEqual(t, body(truncated), 200)
+	assert.Regexp(t, `^\{"log":"a+\.\.\.\[\d+ characters truncated\]\.\.\.","rows":\[1,2,3,[\d,]*"\.\.\.\[\d+ items truncated\]\.\.\."\],"status":"ok"\}$`, encode(truncated))
+
+	summarized := limitResult("query", result, ResultLimit{MaxBytes: 200, Policy: ResultLimitSummarize})
+	assert.Equal(t, ResultTypeResolution, summarized.Type)
+	assert.LessOrEqual(t, body(summarized), 200)
+	assert.Regexp(t, `^\{"log":"a+\.\.\.\[\d+ characters truncated\]\.\.\.z+","rows":\[1,2,[\d,]*"\.\.\.\[\d+ items truncated\]\.\.\.",[\d,]*49,50\],"status":"ok"\}$`, encode(summarized))
+
+	// Short strings and arrays are left intact
+	assert.Contains(t, encode(limitResult("query", result, ResultLimit{MaxBytes: size - 10, Policy: ResultLimitTruncate})), `"rows":[1,2,3,4,5,6,7,8,9,10,`)
+
+	// Results that can't be shortened enough are rejected
+	tooMany := Result{Value: map[string]int{"a": 1, "b": 2, "c": 3}, Type: ResultTypeResolution}
+	assert.Equal(t, ResultTypeRejection, limitResult("query", tooMany, ResultLimit{MaxBytes: 10, Policy: ResultLimitTruncate}).Type)
+
+	// Interrupts and disabled limits are left alone
+	interrupt := Result{Value: value, Type: ResultTypeInterrupt}
+	assert.Equal(t, interrupt, limitResult("query", interrupt, ResultLimit{MaxBytes: 10}))
+	assert.Equal(t, result, limitResult("query", result, ResultLimit{MaxBytes: -1}))
+}
+
+func TestResultLimitOptions(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	_, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		ResultLimit:   &ResultLimit{Policy: "compress"},
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	assert.ErrorContains(t, err, "unknown result limit policy 'compress'")
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		ResultLimit:   &ResultLimit{MaxBytes: 20},
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	list := func(input EchoInput, ctx ContextInput) []string {
+		return strings.Fields(strings.Repeat("item ", 20))
+	}
+	require.NoError(t, i.Tools.Register(Tool{Name: "list", Func: list}))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name:        "truncated",
+		Func:        list,
+		ResultLimit: &ResultLimit{MaxBytes: 140, Policy: ResultLimitTruncate},
+	}))
+	assert.ErrorContains(t, i.Tools.Register(Tool{
+		Name:        "invalid",
+		Func:        list,
+		ResultLimit: &ResultLimit{Policy: "compress"},
+	}), "invalid result limit for tool 'invalid'")
+	require.NoError(t, i.Tools.register())
+
+	rejectedID := server.Enqueue(t, "list", EchoInput{})
+	truncatedID := server.Enqueue(t, "truncated", EchoInput{})
+	require.NoError(t, i.Tools.poll())
+
+	result := server.WaitForResult(t, rejectedID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Contains(t, string(result.Result), "over the limit of 20 bytes")
+
+	result = server.WaitForResult(t, truncatedID)
+	assert.Equal(t, "resolution", result.ResultType)
+	assert.Regexp(t, `^\["item",("item",)*"\.\.\.\[\d+ items truncated\]\.\.\."\]$`, string(result.Result))
+}
+
+func TestDefaultResultLimitFitsRequests(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	// A value of exactly the limit leaves no room for the rest of the request
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "large",
+		Func: func(input EchoInput, ctx ContextInput) string {
+			return strings.Repeat("a", DefaultMaxResultBytes-2)
+		},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	jobID := server.Enqueue(t, "large", EchoInput{})
+	require.NoError(t, i.Tools.poll())
+
+	result := server.WaitForResult(t, jobID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.Contains(t, string(result.Result), fmt.Sprintf("over the limit of %d bytes", DefaultMaxResultBytes))
+}
@@ -0,0 +1,122 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	interruptType = reflect.TypeOf(Interrupt{})
+)
+
+// schemaOptions customizes the schemas reflected for tools.
+type schemaOptions struct {
+	// Doc comments used as descriptions for types and fields
+	comments map[string]string
+	// Schemas for types set with MapType
+	types map[reflect.Type]*jsonschema.Schema
+	// Values of enum types set with RegisterEnum
+	enums map[reflect.Type][]interface{}
+}
+
+// reflectTool validates the signature of a tool function and reflects the
+// schemas of its input and, if it has one, its output.
+func reflectTool(name string, fn interface{}, options schemaOptions) (*jsonschema.Schema, *jsonschema.Schema, error) {
+	// Validate that the function has exactly two arguments, the second of
+	// which is the ContextInput
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return nil, nil, fmt.Errorf("tool '%s' must have a function", name)
+	}
+	if fnType.NumIn() != 2 {
+		return nil, nil, fmt.Errorf("tool '%s' must have exactly two arguments", name)
+	}
+	arg1Type := fnType.In(0)
+	arg2Type := fnType.In(1)
+
+	if arg2Type.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("tool '%s' second argument must be a struct (ContextInput)", name)
+	}
+
+	// Get the schema for the input
+	schema, err := reflectInputSchema(name, arg1Type, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get the schema for the value the tool resolves with, if it has one
+	resultType, ok := outputType(fnType)
+	if !ok {
+		return schema, nil, nil
+	}
+
+	outputSchema, err := reflectSchema(name, resultType, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schema, outputSchema, nil
+}
+
+// reflectSchema returns the JSON schema for a tool's input or output type.
+// Comments, if any, are used as descriptions for the types and fields, and
+// mapped and enum types use the schemas set for them.
+func reflectSchema(name string, t reflect.Type, options schemaOptions) (*jsonschema.Schema, error) {
+	reflector := jsonschema.Reflector{DoNotReference: true, Anonymous: true, AllowAdditionalProperties: false, CommentMap: options.comments, Mapper: typeMapper(options)}
+	schema := reflector.ReflectFromType(t)
+
+	if schema == nil {
+		return nil, fmt.Errorf("failed to get schema for tool '%s'", name)
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Extract the relevant part of the schema
+	defs, ok := schema.Definitions[t.Name()]
+
+	// If the definition is not found, use the whole schema.
+	// This tends to happen for inline structs.
+	// For example: func(input struct { A int `json:"a"` }) int
+	if !ok {
+		defs = schema
+	}
+
+	defsString, err := json.Marshal(defs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema for tool '%s': %v", name, err)
+	}
+
+	if strings.Contains(string(defsString), "\"$ref\":\"#/$defs") {
+		return nil, fmt.Errorf("schema for tool '%s' contains a $ref to an external definition. this is currently not supported. see https://go.inferable.ai/go-schema-limitation for details", name)
+	}
+
+	return defs, nil
+}
+
+// outputType returns the type of the value a tool resolves with: its first
+// return value that is neither an error nor an Interrupt. Tools returning
+// interface{} have no output type.
+func outputType(fnType reflect.Type) (reflect.Type, bool) {
+	for i := 0; i < fnType.NumOut(); i++ {
+		t := fnType.Out(i)
+
+		if t.Implements(errorType) || t == interruptType || t == reflect.PtrTo(interruptType) {
+			continue
+		}
+
+		if t.Kind() == reflect.Interface {
+			return nil, false
+		}
+
+		return t, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,111 @@
+package inferable
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider supplies the API secret. It is consulted before every
+// request, which allows the secret to be rotated without restarting the
+// agent. Implementations must be safe for concurrent use and should be cheap
+// to call.
+type SecretProvider interface {
+	Secret() (string, error)
+}
+
+// SecretFunc adapts a function to the SecretProvider interface.
+type SecretFunc func() (string, error)
+
+func (f SecretFunc) Secret() (string, error) {
+	return f()
+}
+
+type staticSecret string
+
+// StaticSecret returns a SecretProvider that always returns secret.
+func StaticSecret(secret string) SecretProvider {
+	return staticSecret(secret)
+}
+
+func (s staticSecret) Secret() (string, error) {
+	return string(s), nil
+}
+
+type envSecret string
+
+// EnvSecret returns a SecretProvider that reads the secret from the named
+// environment variable (or its _FILE counterpart) on every call.
+func EnvSecret(name string) SecretProvider {
+	return envSecret(name)
+}
+
+func (e envSecret) Secret() (string, error) {
+	secret, err := lookupEnv(string(e))
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateAPISecret(secret); err != nil {
+		return "", fmt.Errorf("invalid secret in %s: %v", string(e), err)
+	}
+
+	return secret, nil
+}
+
+type fileSecret struct {
+	path string
+
+	mu      sync.Mutex
+	secret  string
+	modTime time.Time
+	size    int64
+}
+
+// FileSecret returns a SecretProvider that reads the secret from a file. The
+// file is re-read whenever its modification time or size changes, so the
+// secret can be rotated by replacing the file.
+func FileSecret(path string) SecretProvider {
+	return &fileSecret{path: path}
+}
+
+func (f *fileSecret) Secret() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat secret file: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.secret != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.secret, nil
+	}
+
+	secret, err := readValueFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+
+	if err := validateAPISecret(secret); err != nil {
+		return "", fmt.Errorf("invalid secret in %s: %v", f.path, err)
+	}
+
+	f.secret = secret
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return f.secret, nil
+}
+
+// currentSecret returns the secret to authenticate the next request with.
+func (i *Inferable) currentSecret() (string, error) {
+	secret, err := i.secrets.Secret()
+	if err != nil {
+		return "", fmt.Errorf("failed to get API secret: %v", err)
+	}
+
+	return strings.TrimSpace(secret), nil
+}
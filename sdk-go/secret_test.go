@@ -0,0 +1,120 @@
+package inferable
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSecretReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("first-secret\n"), 0600))
+
+	provider := FileSecret(path)
+	secret, err := provider.Secret()
+	require.NoError(t, err)
+	assert.Equal(t, "first-secret", secret)
+
+	require.NoError(t, os.WriteFile(path, []byte("second-secret-rotated\n"), 0600))
+	// Make sure the change is visible even on filesystems with coarse timestamps
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	secret, err = provider.Secret()
+	require.NoError(t, err)
+	assert.Equal(t, "second-secret-rotated", secret)
+
+	require.NoError(t, os.Remove(path))
+	_, err = provider.Secret()
+	assert.Error(t, err)
+}
+
+func TestEnvSecret(t *testing.T) {
+	t.Setenv("TEST_INFERABLE_SECRET", "env-secret")
+	t.Setenv("TEST_INFERABLE_SECRET_FILE", "")
+
+	provider := EnvSecret("TEST_INFERABLE_SECRET")
+	secret, err := provider.Secret()
+	require.NoError(t, err)
+	assert.Equal(t, "env-secret", secret)
+
+	t.Setenv("TEST_INFERABLE_SECRET", "")
+	_, err = provider.Secret()
+	assert.Error(t, err)
+}
+
+func TestNewValidatesSecretProvider(t *testing.T) {
+	_, err := New(InferableOptions{
+		APIEndpoint:    DefaultAPIEndpoint,
+		SecretProvider: StaticSecret(""),
+		MachineIDPath:  filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	assert.Error(t, err)
+}
+
+func TestReregisterAfterSecretRotation(t *testing.T) {
+	var mu sync.Mutex
+	registeredWith := ""
+	registrations := 0
+	current := "old-secret"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/machines":
+			registeredWith = auth
+			registrations++
+			w.Write([]byte(`{"clusterId": "test-cluster"}`))
+		case "/clusters/test-cluster/jobs":
+			// Machines must re-register when their secret changes
+			if auth != registeredWith {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		SecretProvider: SecretFunc(func() (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return current, nil
+		}),
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "echo",
+		Func: func(input EchoInput, ctx ContextInput) string { return input.Input },
+	}))
+	require.NoError(t, i.Tools.register())
+	require.NoError(t, i.Tools.poll())
+
+	mu.Lock()
+	current = "new-secret"
+	mu.Unlock()
+
+	// The first poll after rotation is rejected and triggers re-registration,
+	// which isn't reported as a failure
+	require.NoError(t, i.Tools.poll())
+	assert.Equal(t, int64(0), i.Tools.retryAfter.Load())
+	require.NoError(t, i.Tools.poll())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, registrations)
+	assert.Equal(t, "Bearer new-secret", registeredWith)
+}
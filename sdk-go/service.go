@@ -0,0 +1,111 @@
+package inferable
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	// DefaultServiceName is the name of the agent available as Inferable.Tools.
+	DefaultServiceName = "default"
+	// DefaultMaxConcurrency is the number of jobs an agent executes at once
+	// unless configured otherwise.
+	DefaultMaxConcurrency = 10
+	// MaxConcurrency is the most jobs the control plane hands out per poll,
+	// and so the most an agent can execute at once.
+	MaxConcurrency = 20
+	// Longest service name accepted by the control plane.
+	maxServiceNameLength = 30
+)
+
+var serviceNamePattern = regexp.MustCompile("^[a-zA-Z0-9]+$")
+
+// ServiceOptions configures an agent created with Inferable.Service.
+type ServiceOptions struct {
+	// MaxConcurrency is the number of jobs the agent executes at once.
+	// Defaults to DefaultMaxConcurrency and can't exceed MaxConcurrency.
+	MaxConcurrency int
+	// PollWaitTime overrides InferableOptions.PollWaitTime for this agent.
+	// Zero inherits the instance's setting, a negative value disables long
+	// polling.
+	PollWaitTime int
+	// IdempotencyStore overrides InferableOptions.IdempotencyStore for this
+	// agent.
+	IdempotencyStore IdempotencyStore
+	// HeartbeatInterval is how often the control plane is told that a job is
+	// still executing. Defaults to DefaultHeartbeatInterval, a negative value
+	// sends heartbeats only when progress is reported.
+	HeartbeatInterval time.Duration
+	// ResultLimit overrides InferableOptions.ResultLimit for this agent.
+	ResultLimit *ResultLimit
+}
+
+// Service creates an additional agent with its own tools, concurrency limit
+// and lifecycle. Each service polls independently, so slow tools in one
+// service don't hold up jobs for another.
+//
+// Tool names are shared across the cluster, so a tool may only be registered
+// with one service per Inferable instance.
+//
+// Example:
+//
+//	reporting, err := client.Service("reporting", inferable.ServiceOptions{
+//	    MaxConcurrency: 2,
+//	})
+//
+//	reporting.Register(inferable.Tool{
+//	    Func: generateReport,
+//	    Name: "GenerateReport",
+//	})
+//
+//	reporting.Listen()
+//	defer reporting.Unlisten()
+func (i *Inferable) Service(name string, options ServiceOptions) (*pollingAgent, error) {
+	if name == DefaultServiceName {
+		return nil, fmt.Errorf("service name '%s' is reserved, use Inferable.Tools instead", name)
+	}
+
+	if len(name) > maxServiceNameLength || !serviceNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("service name '%s' must be 1-%d alphanumeric characters", name, maxServiceNameLength)
+	}
+
+	i.servicesMu.Lock()
+	defer i.servicesMu.Unlock()
+
+	if _, exists := i.services[name]; exists {
+		return nil, fmt.Errorf("service with name '%s' already exists", name)
+	}
+
+	agent, err := i.createPollingAgent(name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	i.services[name] = agent
+	return agent, nil
+}
+
+// agents returns the default agent and all services.
+func (i *Inferable) agents() []*pollingAgent {
+	i.servicesMu.Lock()
+	defer i.servicesMu.Unlock()
+
+	agents := make([]*pollingAgent, 0, len(i.services))
+	for _, agent := range i.services {
+		agents = append(agents, agent)
+	}
+
+	return agents
+}
+
+// toolOwner returns the agent that has registered the named tool, if any.
+func (i *Inferable) toolOwner(name string) *pollingAgent {
+	for _, agent := range i.agents() {
+		if agent.hasTool(name) {
+			return agent
+		}
+	}
+
+	return nil
+}
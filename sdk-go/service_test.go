@@ -3,14 +3,17 @@ package inferable
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"bytes"
 	"net/http"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
 	"github.com/inferablehq/inferable/sdk-go/internal/util"
 )
 
@@ -18,8 +21,9 @@ func TestRegisterFunc(t *testing.T) {
 	_, _, _, apiEndpoint := util.GetTestVars()
 
 	i, _ := New(InferableOptions{
-		APIEndpoint: apiEndpoint,
-		APISecret:   "test-secret",
+		APIEndpoint:   apiEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 	type TestInput struct {
 		A int `json:"a"`
@@ -54,8 +58,9 @@ func TestRegisterFuncWithInlineStruct(t *testing.T) {
 	_, _, _, apiEndpoint := util.GetTestVars()
 
 	i, _ := New(InferableOptions{
-		APIEndpoint: apiEndpoint,
-		APISecret:   "test-secret",
+		APIEndpoint:   apiEndpoint,
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
 	})
 	testFunc := func(input struct {
 		A int `json:"a"`
@@ -270,3 +275,117 @@ func TestServiceStartAndReceiveFailingMessage(t *testing.T) {
 	require.Equal(t, "success", result["status"])
 	require.Equal(t, "test error", result["result"])
 }
+
+func TestServiceReceiveMessageOffline(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   server.Secret,
+		MachineID:   "random-machine-id",
+	})
+	require.NoError(t, err)
+
+	type TestInput struct {
+		Message string `json:"message"`
+	}
+
+	err = i.Tools.Register(Tool{
+		Func:        func(input TestInput, ctx ContextInput) string { return "Received: " + input.Message },
+		Name:        "TestFunc",
+		Description: "Test function",
+	})
+	require.NoError(t, err)
+
+	err = i.Tools.Register(Tool{
+		Func:        func(input TestInput, ctx ContextInput) (*string, error) { return nil, fmt.Errorf("test error") },
+		Name:        "FailingFunc",
+		Description: "Test function",
+	})
+	require.NoError(t, err)
+
+	err = i.Tools.Listen()
+	require.NoError(t, err)
+	defer i.Tools.Unlisten()
+
+	result := server.WaitForResult(t, server.Enqueue(t, "TestFunc", TestInput{Message: "Hello, SQS!"}))
+	require.Equal(t, "resolution", result.ResultType)
+	require.JSONEq(t, `"Received: Hello, SQS!"`, string(result.Result))
+
+	result = server.WaitForResult(t, server.Enqueue(t, "FailingFunc", TestInput{Message: "Hello, SQS!"}))
+	require.Equal(t, "rejection", result.ResultType)
+	require.JSONEq(t, `"test error"`, string(result.Result))
+}
+
+func TestNamedServices(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		PollWaitTime:  1,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	_, err = i.Service(DefaultServiceName, ServiceOptions{})
+	assert.Error(t, err)
+	_, err = i.Service("not valid", ServiceOptions{})
+	assert.Error(t, err)
+	_, err = i.Service("reporting", ServiceOptions{MaxConcurrency: MaxConcurrency + 1})
+	assert.Error(t, err)
+
+	reporting, err := i.Service("reporting", ServiceOptions{MaxConcurrency: 2})
+	require.NoError(t, err)
+	_, err = i.Service("reporting", ServiceOptions{})
+	assert.Error(t, err)
+
+	// Blocks until released, so that reporting jobs are slow
+	release := make(chan struct{})
+	running := make(chan struct{}, 10)
+	require.NoError(t, reporting.Register(Tool{
+		Name: "report",
+		Func: func(input EchoInput, ctx ContextInput) string {
+			running <- struct{}{}
+			<-release
+			return "report " + input.Input
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+
+	// Tool names are unique across services
+	assert.Error(t, i.Tools.Register(Tool{Name: "report", Func: echo}))
+
+	require.NoError(t, reporting.Listen())
+	defer reporting.Unlisten()
+	require.NoError(t, i.Tools.Listen())
+	defer i.Tools.Unlisten()
+
+	reports := []string{}
+	for n := 0; n < 3; n++ {
+		reports = append(reports, server.Enqueue(t, "report", EchoInput{Input: fmt.Sprint(n)}))
+	}
+
+	// Only two reports may run at once
+	<-running
+	<-running
+	select {
+	case <-running:
+		t.Fatal("expected at most 2 concurrent reports")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The default service isn't held up by slow reports
+	result := server.WaitForResult(t, server.Enqueue(t, "echo", EchoInput{Input: "fast"}))
+	assert.JSONEq(t, `"fast"`, string(result.Result))
+
+	close(release)
+	for _, id := range reports {
+		assert.Equal(t, "resolution", server.WaitForResult(t, id).ResultType)
+	}
+
+	// Each service has its own lifecycle
+	reporting.Unlisten()
+	assert.False(t, reporting.isPolling())
+	assert.True(t, i.Tools.isPolling())
+}
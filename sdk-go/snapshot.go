@@ -0,0 +1,85 @@
+package inferable
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/inferablehq/inferable/sdk-go/internal/schemadiff"
+)
+
+// SchemaChange is a difference between two schema snapshots. Breaking
+// changes are those that may make running agents or their callers fail, such
+// as removed fields, newly required fields or narrowed enums.
+type SchemaChange = schemadiff.Change
+
+// SchemaSnapshot returns the input and output schemas of the agent's tools as
+// indented JSON, suitable for committing alongside the code so that schema
+// changes show up in review.
+func (s *pollingAgent) SchemaSnapshot() ([]byte, error) {
+	schema, err := s.getSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema snapshot: %v", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// WriteSchemaSnapshot writes the agent's schema snapshot to path.
+func (s *pollingAgent) WriteSchemaSnapshot(path string) error {
+	data, err := s.SchemaSnapshot()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// CheckSchemaSnapshot compares the agent's schemas with the snapshot at path,
+// returning the changes since it was written.
+//
+// Example:
+//
+//	changes, err := client.Tools.CheckSchemaSnapshot("schemas.json")
+//	for _, change := range changes {
+//	    if change.Breaking {
+//	        log.Printf("breaking schema change: %s", change)
+//	    }
+//	}
+func (s *pollingAgent) CheckSchemaSnapshot(path string) ([]SchemaChange, error) {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema snapshot: %v", err)
+	}
+
+	current, err := s.SchemaSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSchemaSnapshots(old, current)
+}
+
+// DiffSchemaSnapshots returns the changes between two schema snapshots.
+func DiffSchemaSnapshots(old, new []byte) ([]SchemaChange, error) {
+	oldSnapshot, err := schemadiff.Parse(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newSnapshot, err := schemadiff.Parse(new)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemadiff.Diff(oldSnapshot, newSnapshot), nil
+}
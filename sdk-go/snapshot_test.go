@@ -0,0 +1,53 @@
+package inferable
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaSnapshot(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "https://api.inferable.ai",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	_, err = i.Tools.SchemaSnapshot()
+	assert.Error(t, err, "there are no tools to snapshot")
+
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	require.NoError(t, i.Tools.WriteSchemaSnapshot(path))
+
+	changes, err := i.Tools.CheckSchemaSnapshot(path)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// A new required field and a new tool are reported, only the first as breaking
+	require.NoError(t, i.Tools.Unregister("echo"))
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "echo",
+		Func: func(input struct {
+			Input  string
+			Prefix string `json:"prefix"`
+		}, ctx ContextInput) string {
+			return input.Prefix + input.Input
+		},
+	}))
+	require.NoError(t, i.Tools.Register(Tool{Name: "reverse", Func: reverse}))
+
+	changes, err = i.Tools.CheckSchemaSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, []SchemaChange{
+		{Tool: "echo", Path: "input.prefix", Message: "required property added", Breaking: true},
+		{Tool: "reverse", Message: "tool added"},
+	}, changes)
+
+	_, err = i.Tools.CheckSchemaSnapshot(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
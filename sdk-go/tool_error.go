@@ -0,0 +1,90 @@
+package inferable
+
+import (
+	"errors"
+	"log"
+	"reflect"
+)
+
+// ToolError is an error a tool can return to reject a call with structured
+// information, rather than just a message. It is sent to the control plane
+// as an object with the code, message, details and retryable flag, so the
+// model can act on it. Internal is logged but never sent.
+//
+// Tools may also wrap a ToolError, for example with fmt.Errorf and %w; the
+// ToolError is sent rather than the wrapping message.
+//
+// Example:
+//
+//	return nil, &inferable.ToolError{
+//	    Code:      "card_declined",
+//	    Message:   "The card was declined, ask the customer for another card",
+//	    Details:   map[string]interface{}{"last4": "4242"},
+//	    Retryable: false,
+//	    Internal:  fmt.Sprintf("gateway response: %v", resp),
+//	}
+type ToolError struct {
+	// A short, stable identifier for the kind of error
+	Code string `json:"code,omitempty"`
+	// Describes the error to the model and the user
+	Message string `json:"message"`
+	// Additional information, such as the fields that were invalid
+	Details map[string]interface{} `json:"details,omitempty"`
+	// Whether calling the tool again with the same input may succeed
+	Retryable bool `json:"retryable"`
+	// Logged by the agent, but not sent to the control plane
+	Internal string `json:"-"`
+}
+
+// NewToolError creates a ToolError with a code and message.
+func NewToolError(code, message string) *ToolError {
+	return &ToolError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *ToolError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	if e.Code == "" {
+		return e.Message
+	}
+	return e.Code + ": " + e.Message
+}
+
+// rejectionValue returns the value sent for a rejection with err: the
+// ToolError, if err is or wraps one, otherwise the error message.
+func rejectionValue(err error) interface{} {
+	if isNilError(err) {
+		return nil
+	}
+
+	var toolError *ToolError
+	if !errors.As(err, &toolError) || toolError == nil {
+		return err.Error()
+	}
+
+	if toolError.Internal != "" {
+		log.Printf("Tool error %s: %s", toolError.Error(), toolError.Internal)
+	}
+
+	// Copy so that later changes to the error don't affect the result
+	value := *toolError
+	return &value
+}
+
+// isNilError reports whether err is nil, including a nil pointer returned
+// as an error, such as a tool returning a nil *ToolError on success.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	v := reflect.ValueOf(err)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package inferable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inferablehq/inferable/sdk-go/inferabletest"
+)
+
+func TestToolError(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint:   server.URL,
+		APISecret:     server.Secret,
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "charge",
+		Func: func(input EchoInput, ctx ContextInput) (string, error) {
+			switch input.Input {
+			case "declined":
+				return "", &ToolError{
+					Code:      "card_declined",
+					Message:   "The card was declined",
+					Details:   map[string]interface{}{"last4": "4242"},
+					Retryable: true,
+					Internal:  "gateway returned 402",
+				}
+			case "wrapped":
+				return "", fmt.Errorf("charging card: %w", NewToolError("invalid_amount", "The amount must be positive"))
+			default:
+				return "", fmt.Errorf("gateway timeout")
+			}
+		},
+	}))
+	require.NoError(t, i.Tools.register())
+
+	declined := server.Enqueue(t, "charge", EchoInput{Input: "declined"})
+	wrapped := server.Enqueue(t, "charge", EchoInput{Input: "wrapped"})
+	plain := server.Enqueue(t, "charge", EchoInput{Input: "plain"})
+	require.NoError(t, i.Tools.poll())
+
+	// The internal message isn't sent
+	result, _ := server.Result(declined)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.JSONEq(t, `{
+		"code": "card_declined",
+		"message": "The card was declined",
+		"details": {"last4": "4242"},
+		"retryable": true
+	}`, string(result.Result))
+
+	result, _ = server.Result(wrapped)
+	assert.JSONEq(t, `{"code":"invalid_amount","message":"The amount must be positive","retryable":false}`, string(result.Result))
+
+	// Plain errors are still sent as their message
+	result, _ = server.Result(plain)
+	assert.JSONEq(t, `"gateway timeout"`, string(result.Result))
+
+	invoked, err := i.Tools.Invoke(context.Background(), "charge", json.RawMessage(`{"Input":"declined"}`), ContextInput{})
+	require.NoError(t, err)
+	toolError, ok := invoked.Value.(*ToolError)
+	require.True(t, ok)
+	assert.Equal(t, "card_declined: The card was declined", toolError.Error())
+}
+
+func TestMiddlewareToolError(t *testing.T) {
+	server := inferabletest.NewServer(t)
+
+	i, err := New(InferableOptions{
+		APIEndpoint: server.URL,
+		APISecret:   server.Secret,
+		MachineID:   "test-machine",
+	})
+	require.NoError(t, err)
+
+	// Such as an auth check rejecting the call before the tool runs
+	i.Tools.Use(func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call ToolCall) (Result, error) {
+			if call.Context.AuthContext == nil {
+				return Result{}, &ToolError{
+					Code:      "unauthorized",
+					Message:   "The user is not signed in",
+					Details:   map[string]interface{}{"scope": "orders"},
+					Retryable: false,
+				}
+			}
+			return next(ctx, call)
+		}
+	})
+	require.NoError(t, i.Tools.Register(Tool{Name: "echo", Func: echo}))
+	require.NoError(t, i.Tools.register())
+
+	jobID := server.Enqueue(t, "echo", EchoInput{Input: "hello"})
+	require.NoError(t, i.Tools.poll())
+
+	result := server.WaitForResult(t, jobID)
+	assert.Equal(t, "rejection", result.ResultType)
+	assert.JSONEq(t, `{
+		"code": "unauthorized",
+		"message": "The user is not signed in",
+		"details": {"scope": "orders"},
+		"retryable": false
+	}`, string(result.Result))
+}
+
+func TestTypedNilToolError(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "lookup",
+		Func: func(input EchoInput, ctx ContextInput) (string, *ToolError) {
+			if input.Input == "" {
+				return "", NewToolError("missing_input", "Input is required")
+			}
+			return "found " + input.Input, nil
+		},
+	}))
+
+	result, err := i.Tools.Invoke(context.Background(), "lookup", json.RawMessage(`{"Input":"order"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeResolution, result.Type)
+	assert.Equal(t, "found order", result.Value)
+
+	result, err = i.Tools.Invoke(context.Background(), "lookup", json.RawMessage(`{"Input":""}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, ResultTypeRejection, result.Type)
+
+	var nilError *ToolError
+	assert.Equal(t, ResultTypeResolution, NewResult("found", nilError).Type)
+	assert.Equal(t, "<nil>", nilError.Error())
+	assert.Nil(t, rejectionValue(nilError))
+}
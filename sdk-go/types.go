@@ -0,0 +1,107 @@
+package inferable
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+
+	"github.com/invopop/jsonschema"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	schemaerType      = reflect.TypeOf((*interface{ JSONSchema() *jsonschema.Schema })(nil)).Elem()
+)
+
+// MapType sets the schema used for a type in the input and output schemas
+// of tools registered afterwards, for types that don't reflect into a
+// schema matching how they marshal, such as IDs and decimals from other
+// packages. A nil schema removes the mapping.
+//
+// Types that have a JSONSchema() *jsonschema.Schema method use its schema
+// without being mapped, and types that marshal to JSON through MarshalText
+// are strings unless mapped otherwise.
+//
+// Example:
+//
+//	client.Tools.MapType(reflect.TypeOf(uuid.UUID{}), &jsonschema.Schema{Type: "string", Format: "uuid"})
+//	client.Tools.MapType(reflect.TypeOf(decimal.Decimal{}), &jsonschema.Schema{Type: "string", Pattern: `^-?\d+(\.\d+)?$`})
+func (s *pollingAgent) MapType(t reflect.Type, schema *jsonschema.Schema) {
+	s.typesMu.Lock()
+	defer s.typesMu.Unlock()
+
+	if schema == nil {
+		delete(s.types, t)
+		return
+	}
+
+	if s.types == nil {
+		s.types = make(map[reflect.Type]*jsonschema.Schema)
+	}
+	s.types[t] = schema
+}
+
+// typeMap returns a copy of the types mapped with MapType.
+func (s *pollingAgent) typeMap() map[reflect.Type]*jsonschema.Schema {
+	s.typesMu.Lock()
+	defer s.typesMu.Unlock()
+
+	types := make(map[reflect.Type]*jsonschema.Schema, len(s.types))
+	for t, schema := range s.types {
+		types[t] = schema
+	}
+	return types
+}
+
+// typeMapper returns a jsonschema.Reflector Mapper for the mapped types,
+// enums, JSONSchema methods with pointer receivers, which the reflector only
+// honors on values, and text marshalers.
+func typeMapper(options schemaOptions) func(reflect.Type) *jsonschema.Schema {
+	return func(t reflect.Type) *jsonschema.Schema {
+		if schema, ok := options.types[t]; ok {
+			return copySchema(schema)
+		}
+
+		if schema := enumSchema(t, options.enums); schema != nil {
+			return schema
+		}
+
+		switch {
+		case t.Kind() == reflect.Ptr:
+			return nil
+		case t.Implements(schemaerType):
+			// Reflected by the reflector itself
+			return nil
+		case reflect.PtrTo(t).Implements(schemaerType):
+			return reflect.New(t).Interface().(interface{ JSONSchema() *jsonschema.Schema }).JSONSchema()
+		case implements(t, jsonMarshalerType):
+			return nil
+		case implements(t, textMarshalerType):
+			return &jsonschema.Schema{Type: "string"}
+		}
+
+		return nil
+	}
+}
+
+// implements reports whether a type or a pointer to it implements an
+// interface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// copySchema returns a deep copy of a schema, as the reflector modifies the
+// schemas of fields to add descriptions and other tags.
+func copySchema(schema *jsonschema.Schema) *jsonschema.Schema {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema
+	}
+
+	copied := &jsonschema.Schema{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return schema
+	}
+	return copied
+}
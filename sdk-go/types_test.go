@@ -0,0 +1,113 @@
+package inferable
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type OrderID [4]byte
+
+func (id OrderID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(id[:])), nil
+}
+
+func (id *OrderID) UnmarshalText(text []byte) error {
+	_, err := hex.Decode(id[:], text)
+	return err
+}
+
+type Money struct {
+	cents int64
+}
+
+func (m *Money) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "string", Pattern: `^\d+\.\d{2}$`}
+}
+
+type RefundInput struct {
+	Order    OrderID    `json:"order" jsonschema:"description=The order to refund"`
+	Original OrderID    `json:"original"`
+	Amount   Money      `json:"amount"`
+	Client   netip.Addr `json:"client"`
+	At       time.Time  `json:"at"`
+}
+
+func TestMapType(t *testing.T) {
+	i, err := New(InferableOptions{
+		APIEndpoint:   "http://localhost",
+		APISecret:     "test-secret",
+		MachineIDPath: filepath.Join(t.TempDir(), "machine_id.json"),
+	})
+	require.NoError(t, err)
+
+	refund := func(input RefundInput, ctx ContextInput) (OrderID, error) { return input.Order, nil }
+
+	// By default, text marshalers are strings and JSONSchema methods are used
+	require.NoError(t, i.Tools.Register(Tool{Name: "refund", Func: refund}))
+
+	schema, err := json.Marshal(i.Tools.Tools()["refund"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"order": {"type": "string", "description": "The order to refund"},
+			"original": {"type": "string"},
+			"amount": {"type": "string", "pattern": "^\\d+\\.\\d{2}$"},
+			"client": {"type": "string"},
+			"at": {"type": "string", "format": "date-time"}
+		},
+		"required": ["order", "original", "amount", "client", "at"],
+		"additionalProperties": false
+	}`, string(schema))
+
+	i.Tools.MapType(reflect.TypeOf(OrderID{}), &jsonschema.Schema{Type: "string", Pattern: "^[0-9a-f]{8}$"})
+	require.NoError(t, i.Tools.Unregister("refund"))
+	require.NoError(t, i.Tools.Register(Tool{Name: "refund", Func: refund}))
+
+	schema, err = json.Marshal(i.Tools.Tools()["refund"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string", "pattern": "^[0-9a-f]{8}$", "description": "The order to refund"}`, propertyJSON(t, schema, "order"))
+	assert.JSONEq(t, `{"type": "string", "pattern": "^[0-9a-f]{8}$"}`, propertyJSON(t, schema, "original"), "fields don't share the mapped schema")
+
+	output, err := json.Marshal(i.Tools.Tools()["refund"].outputSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "string", "pattern": "^[0-9a-f]{8}$"}`, string(output))
+
+	// Removing the mapping restores the default
+	i.Tools.MapType(reflect.TypeOf(OrderID{}), nil)
+	require.NoError(t, i.Tools.Unregister("refund"))
+	require.NoError(t, i.Tools.Register(Tool{Name: "refund", Func: refund}))
+
+	schema, err = json.Marshal(i.Tools.Tools()["refund"].schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string"}`, propertyJSON(t, schema, "original"))
+
+	// Inputs decoded from text are wrapped like other non-struct inputs
+	require.NoError(t, i.Tools.Register(Tool{
+		Name: "ping",
+		Func: func(addr netip.Addr, ctx ContextInput) bool { return addr.Is4() },
+	}))
+
+	result, err := i.Tools.Invoke(context.Background(), "ping", json.RawMessage(`{"value": "10.0.0.1"}`), ContextInput{})
+	require.NoError(t, err)
+	assert.Equal(t, true, result.Value)
+}
+
+func propertyJSON(t *testing.T, schema []byte, name string) string {
+	var parsed struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(schema, &parsed))
+	return string(parsed.Properties[name])
+}
@@ -42,7 +42,7 @@ export const interruptSchema = z.discriminatedUnion("type", [
 export const blobSchema = z.object({
   id: z.string(),
   name: z.string(),
-  type: z.enum(["application/json", "image/png", "image/jpeg"]),
+  type: z.enum(["application/json", "image/png", "image/jpeg", "application/pdf", "text/csv"]),
   encoding: z.enum(["base64"]),
   size: z.number(),
   createdAt: z.date(),
@@ -488,6 +488,12 @@ export const definition = {
         .boolean()
         .default(false)
         .describe("Should retrieved Jobs be marked as running"),
+      waitTime: z.coerce
+        .number()
+        .min(0)
+        .max(20)
+        .optional()
+        .describe("Time in seconds to keep the request open waiting for jobs"),
     }),
     pathParams: z.object({
       clusterId: z.string(),